@@ -14,7 +14,7 @@ var buildCmd = &cobra.Command{
 	Args:    cobra.ExactArgs(1),
 	Aliases: []string{"do"},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.Run(args[0], configDir, stackPath, buildersPath, reserve, dryRun, server, noStrict, stdout); err != nil {
+		if err := client.Run(args[0], configDir, stackPath, buildersPath, reserve, dryRun, allowMissing, server, noStrict, stdout, goldenDir, updateGolden); err != nil {
 			return fmt.Errorf(errors.Details(err, nil))
 		}
 		return nil
@@ -6,14 +6,42 @@ import (
 	"cuelang.org/go/cue/errors"
 	"github.com/spf13/cobra"
 	"github.com/stakpak/devx/pkg/client"
+	"github.com/stakpak/devx/pkg/diff"
+)
+
+var (
+	diffArtifacts bool
+	diffEnvA      string
+	diffEnvB      string
+	diffIgnore    []string
+	diffOutput    string
 )
 
 var diffCmd = &cobra.Command{
 	Use:   "diff [environment] [target git revision]",
-	Short: "Diff the current stack with that @ target (e.g. HEAD, commit, tag).",
-	Args:  cobra.ExactArgs(2),
+	Short: "Diff the current stack with that @ target (e.g. HEAD, commit, tag), or two environments with --env-a and --env-b.",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if diffEnvA != "" || diffEnvB != "" {
+			if diffEnvA == "" || diffEnvB == "" {
+				return fmt.Errorf("--env-a and --env-b must be used together")
+			}
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := client.Diff(args[1], args[0], configDir, stackPath, buildersPath, server, noStrict); err != nil {
+		var err error
+		if diffEnvA != "" {
+			err = client.DiffEnvironments(diffEnvA, diffEnvB, configDir, stackPath, buildersPath, server, noStrict, diffArtifacts, diffIgnore, diffOutput)
+		} else {
+			err = client.Diff(args[1], args[0], configDir, stackPath, buildersPath, server, noStrict, diffArtifacts, diffIgnore, diffOutput)
+		}
+		if errors.Is(err, diff.ErrChanges) {
+			// reported through the exit code only
+			cmd.SilenceErrors = true
+			return err
+		}
+		if err != nil {
 			return fmt.Errorf(errors.Details(err, nil))
 		}
 		return nil
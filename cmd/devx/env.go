@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue/errors"
+	"github.com/spf13/cobra"
+	"github.com/stakpak/devx/pkg/client"
+)
+
+var envOutput string
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Inspect the environments configured by builders",
+}
+
+var envListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List environments with their builder version, flows, drivers and outputs",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := client.EnvList(configDir, buildersPath); err != nil {
+			return fmt.Errorf(errors.Details(err, nil))
+		}
+		return nil
+	},
+}
+
+var envShowCmd = &cobra.Command{
+	Use:   "show [environment]",
+	Short: "Print the fully resolved builder of an environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := client.EnvShow(args[0], configDir, buildersPath, envOutput); err != nil {
+			return fmt.Errorf(errors.Details(err, nil))
+		}
+		return nil
+	},
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue/errors"
+	"github.com/spf13/cobra"
+	"github.com/stakpak/devx/pkg/client"
+)
+
+var graphFormat string
+
+var graphCmd = &cobra.Command{
+	Use:   "graph [environment]",
+	Short: "Render the component dependency graph, transformed for an environment if specified",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		environment := ""
+		if len(args) == 1 {
+			environment = args[0]
+		}
+		if err := client.Graph(environment, configDir, stackPath, buildersPath, noStrict, graphFormat); err != nil {
+			return fmt.Errorf(errors.Details(err, nil))
+		}
+		return nil
+	},
+}
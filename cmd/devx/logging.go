@@ -6,6 +6,7 @@ import (
 	"github.com/fatih/color"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/stakpak/devx/pkg/redact"
 )
 
 type PlainFormatter struct {
@@ -17,6 +18,7 @@ func (f *PlainFormatter) Format(entry *log.Entry) ([]byte, error) {
 
 func setupLogging(cmd *cobra.Command, args []string) {
 	color.NoColor = noColor
+	log.AddHook(&redact.LogHook{})
 
 	if verbosity == "debug" {
 		log.Info("Debug logs enabled")
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -10,6 +11,8 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/diff"
+	"github.com/stakpak/devx/pkg/redact"
 )
 
 var (
@@ -20,6 +23,9 @@ var (
 	showDefs         bool
 	showTransformers bool
 	dryRun           bool
+	allowMissing     bool
+	goldenDir        string
+	updateGolden     bool
 	noColor          bool
 	noStrict         bool
 	verbosity        string
@@ -51,9 +57,25 @@ func init() {
 	buildCmd.PersistentFlags().BoolVarP(&reserve, "reserve", "r", false, "reserve build resources")
 	buildCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "d", false, "output the entire stack after transformation without applying drivers")
 	buildCmd.PersistentFlags().BoolVarP(&stdout, "stdout", "o", false, "output result to stdout")
+	buildCmd.PersistentFlags().StringVar(&goldenDir, "check-golden", "", "compare driver output with the golden files in this directory instead of writing it")
+	buildCmd.PersistentFlags().BoolVar(&updateGolden, "update-golden", false, "rewrite the golden files with the driver output (requires --check-golden)")
+	buildCmd.PersistentFlags().BoolVar(&allowMissing, "allow-missing", false, "fill generated fields that cannot be resolved with placeholders (requires --dry-run)")
 	discoverCmd.PersistentFlags().BoolVarP(&showDefs, "definitions", "d", false, "show definitions")
 	discoverCmd.PersistentFlags().BoolVarP(&showTransformers, "transformers", "t", false, "show transformers")
 	reserveCmd.PersistentFlags().BoolVarP(&dryRun, "dry-run", "d", false, "attempt reserving stack resources")
+	graphCmd.PersistentFlags().StringVarP(&graphFormat, "format", "f", "dot", "graph output format *dot | mermaid | json")
+	updateCmd.PersistentFlags().BoolVar(&updateFrozen, "frozen", false, "install the versions in cue.mod/devx.lock and fail if it is out of date")
+	validateCmd.PersistentFlags().StringVarP(&validateOutput, "output", "o", "text", "diagnostics output format *text | json | sarif")
+	lintCmd.PersistentFlags().StringVarP(&lintOutput, "output", "o", "text", "diagnostics output format *text | json | sarif")
+	testCmd.PersistentFlags().StringVarP(&testOutput, "output", "o", "text", "test results output format *text | junit")
+	testCmd.PersistentFlags().StringVar(&testRun, "run", "", "only run tests with names matching the regular expression")
+	diffCmd.PersistentFlags().BoolVar(&diffArtifacts, "artifacts", false, "diff the files generated by drivers instead of the transformed stack")
+	diffCmd.PersistentFlags().StringVar(&diffEnvA, "env-a", "", "environment to compare from, instead of a git revision")
+	diffCmd.PersistentFlags().StringVar(&diffEnvB, "env-b", "", "environment to compare to, instead of a git revision")
+	diffCmd.PersistentFlags().StringVarP(&diffOutput, "output", "o", "text", "diff output format *text | json | markdown, exits with code 2 when changes are found")
+	diffCmd.PersistentFlags().StringArrayVar(&diffIgnore, "ignore", []string{}, "stack paths to ignore, e.g. *.$resources.*.namespace or **.labels")
+	envShowCmd.PersistentFlags().StringVarP(&envOutput, "output", "o", "yaml", "output format *yaml | json")
+	depsCmd.PersistentFlags().StringVarP(&depsOutput, "output", "o", "text", "output format *text | json")
 
 	runCmd.PersistentFlags().BoolVar(&runFlags.Verbose, "verbose", false, "enables verbose mode")
 	runCmd.PersistentFlags().BoolVar(&runFlags.Parallel, "parallel", false, "executes tasks provided on command line in parallel")
@@ -81,16 +103,32 @@ func init() {
 		loginCmd,
 		retireCmd,
 		xrayCmd,
+		graphCmd,
+		envCmd,
+		testCmd,
+	)
+
+	envCmd.AddCommand(
+		envListCmd,
+		envShowCmd,
 	)
 
 	projectCmd.AddCommand(
 		initCmd,
 		updateCmd,
 		validateCmd,
+		lintCmd,
 		discoverCmd,
 		genCmd,
 		publishCmd,
 		importCmd,
+		depsCmd,
+	)
+
+	depsCmd.AddCommand(
+		depsTreeCmd,
+		depsOutdatedCmd,
+		depsWhyCmd,
 	)
 
 	publishCmd.AddCommand(
@@ -151,7 +189,11 @@ func preRun(cmd *cobra.Command, args []string) {
 }
 
 func main() {
+	rootCmd.SetErr(&redact.Writer{W: os.Stderr})
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, diff.ErrChanges) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
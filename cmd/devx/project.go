@@ -8,6 +8,7 @@ import (
 
 	"cuelang.org/go/cue/errors"
 	"github.com/stakpak/devx/pkg/catalog"
+	"github.com/stakpak/devx/pkg/client"
 	"github.com/stakpak/devx/pkg/policy"
 	"github.com/stakpak/devx/pkg/project"
 )
@@ -34,23 +35,74 @@ var initCmd = &cobra.Command{
 	},
 }
 
+var updateFrozen bool
+
 var updateCmd = &cobra.Command{
 	Use:   "update",
 	Short: "Update/Install project dependencies",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := project.Update(configDir, server); err != nil {
+		if err := project.Update(configDir, server, updateFrozen); err != nil {
 			return err
 		}
 		return nil
 	},
 }
 
+var depsOutput string
+
+var depsCmd = &cobra.Command{
+	Use:   "deps",
+	Short: "Inspect project dependencies",
+}
+
+var depsTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Print direct and nested dependencies with their resolved versions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return project.DepsTree(configDir, server, depsOutput)
+	},
+}
+
+var depsOutdatedCmd = &cobra.Command{
+	Use:   "outdated",
+	Short: "List dependencies with newer git tags or Hub versions",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return project.DepsOutdated(configDir, server, depsOutput)
+	},
+}
+
+var depsWhyCmd = &cobra.Command{
+	Use:   "why [module]",
+	Short: "Explain which dependency paths pull in a module",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return project.DepsWhy(configDir, server, args[0], depsOutput)
+	},
+}
+
+var validateOutput string
+
 var validateCmd = &cobra.Command{
 	Use:     "validate",
 	Aliases: []string{"v"},
 	Short:   "Validate configurations",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if err := project.Validate(configDir, stackPath, buildersPath, noStrict); err != nil {
+		if err := project.Validate(configDir, stackPath, buildersPath, noStrict, validateOutput); err != nil {
+			return fmt.Errorf(errors.Details(err, nil))
+		}
+		return nil
+	},
+}
+
+var lintOutput string
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [environment...]",
+	Short: "Check the stack against lint rules, before and after transformation",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := client.Lint(args, configDir, stackPath, buildersPath, noStrict, lintOutput); err != nil {
 			return fmt.Errorf(errors.Details(err, nil))
 		}
 		return nil
@@ -130,7 +182,7 @@ var publishModuleCmd = &cobra.Command{
 }
 
 var importCmd = &cobra.Command{
-	Use:   "import [<git repo>@<git revision>]",
+	Use:   "import [<git repo>@<git revision> | <local path>]",
 	Short: "Import a dependency",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
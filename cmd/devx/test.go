@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue/errors"
+	"github.com/spf13/cobra"
+	"github.com/stakpak/devx/pkg/client"
+)
+
+var (
+	testOutput string
+	testRun    string
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run transformer, flow and policy tests defined in *_test.cue files",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := client.Test(configDir, buildersPath, testRun, testOutput); err != nil {
+			return fmt.Errorf(errors.Details(err, nil))
+		}
+		return nil
+	},
+}
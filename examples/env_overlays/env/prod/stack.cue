@@ -0,0 +1,4 @@
+package main
+
+// only loaded when building the prod environment
+stack: components: cowsay: containers: default: args: ["Hello Prod!"]
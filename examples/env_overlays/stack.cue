@@ -0,0 +1,19 @@
+package main
+
+import (
+	"stakpak.dev/devx/v1"
+	"stakpak.dev/devx/v1/traits"
+)
+
+stack: v1.#Stack & {
+	components: {
+		cowsay: {
+			traits.#Workload
+			containers: default: {
+				image: "docker/whalesay"
+				command: ["cowsay"]
+				args: *["Hello DEV!"] | [...string]
+			}
+		}
+	}
+}
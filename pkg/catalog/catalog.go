@@ -46,9 +46,18 @@ type ModuleDependency struct {
 	V *string `json:"v,omitempty"`
 }
 
+// ModuleReplacement replaces a git dependency with a local directory or with
+// another git repo and revision
+type ModuleReplacement struct {
+	Path string  `json:"path,omitempty"`
+	Repo string  `json:"repo,omitempty"`
+	V    *string `json:"v,omitempty"`
+}
+
 type ModuleCUE struct {
-	Module       string                      `json:"module"`
-	Dependencies map[string]ModuleDependency `json:"deps"`
+	Module       string                       `json:"module"`
+	Dependencies map[string]ModuleDependency  `json:"deps"`
+	Replace      map[string]ModuleReplacement `json:"replace,omitempty"`
 	Cue          struct {
 		Language string `json:"lang"`
 	} `json:"cue,omitempty"`
@@ -5,30 +5,44 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"strings"
+	"path/filepath"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/format"
-	"github.com/fatih/color"
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/osfs"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/stakpak/devx/pkg/auth"
 	"github.com/stakpak/devx/pkg/drivers"
+	"github.com/stakpak/devx/pkg/golden"
+	"github.com/stakpak/devx/pkg/policy"
 	"github.com/stakpak/devx/pkg/project"
+	"github.com/stakpak/devx/pkg/redact"
 	"github.com/stakpak/devx/pkg/stack"
 	"github.com/stakpak/devx/pkg/stackbuilder"
 	"github.com/stakpak/devx/pkg/utils"
 )
 
-func Run(environment string, configDir string, stackPath string, buildersPath string, reserve bool, dryRun bool, server auth.ServerConfig, noStrict bool, stdout bool) error {
+func Run(environment string, configDir string, stackPath string, buildersPath string, reserve bool, dryRun bool, allowMissing bool, server auth.ServerConfig, noStrict bool, stdout bool, goldenDir string, updateGolden bool) error {
+	if allowMissing && !dryRun {
+		return fmt.Errorf("--allow-missing can only be used with --dry-run")
+	}
+	if updateGolden && goldenDir == "" {
+		return fmt.Errorf("--update-golden can only be used with --check-golden")
+	}
+	if err := utils.ValidateEnvironment(environment); err != nil {
+		return err
+	}
+
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
-	ctx = context.WithValue(ctx, utils.DryRunKey, dryRun)
+	// golden checks never apply the stack and need stable generated values
+	ctx = context.WithValue(ctx, utils.DryRunKey, dryRun || goldenDir != "")
+	ctx = context.WithValue(ctx, utils.AllowMissingKey, allowMissing)
 
-	if err := project.Update(configDir, server); err != nil {
+	if err := project.EnsureDependencies(configDir, server); err != nil {
 		return err
 	}
 
@@ -45,12 +59,20 @@ func Run(environment string, configDir string, stackPath string, buildersPath st
 		return err
 	}
 
+	if goldenDir != "" {
+		return checkGolden(environment, configDir, stack, builder, goldenDir, updateGolden)
+	}
+
 	if dryRun {
-		log.Info(stack.GetComponents())
+		components, err := stack.RedactedComponents()
+		if err != nil {
+			return err
+		}
+		log.Info(components)
 		return nil
 	}
 
-	for id, driver := range drivers.NewDriversMap(environment, builder.DriverConfig) {
+	for id, driver := range drivers.NewDriversMap(environment, builder.DriverConfig, osfs.Default) {
 		if err := driver.ApplyAll(stack, stdout); err != nil {
 			newErr := fmt.Errorf("error running %s driver: %s", id, errors.Details(err, nil))
 			if auth.IsLoggedIn(server) {
@@ -87,157 +109,118 @@ func Run(environment string, configDir string, stackPath string, buildersPath st
 	return nil
 }
 
-func Diff(target string, environment string, configDir string, stackPath string, buildersPath string, server auth.ServerConfig, noStrict bool) error {
-	log.Infof("📍 Processing target stack @ %s", target)
-	targetDir, err := os.MkdirTemp("", "devx-target-*")
-	if err != nil {
-		return err
-	}
-	defer os.RemoveAll(targetDir)
-
-	repo, err := git.PlainClone(targetDir, false, &git.CloneOptions{
-		URL: configDir,
-	})
-	if err != nil {
-		return err
+// checkGolden runs the drivers in memory and compares their output with the
+// snapshots in goldenDir, or rewrites the snapshots if update is set
+func checkGolden(environment string, configDir string, stack *stack.Stack, builder *stackbuilder.StackBuilder, goldenDir string, update bool) error {
+	absGoldenDir, _ := filepath.Abs(goldenDir)
+	absConfigDir, _ := filepath.Abs(configDir)
+	cwd, _ := os.Getwd()
+	if absGoldenDir == absConfigDir || absGoldenDir == cwd {
+		return fmt.Errorf("golden directory %s must not be the project directory", goldenDir)
 	}
 
-	w, err := repo.Worktree()
-	if err != nil {
-		return err
+	fs := memfs.New()
+	for id, driver := range drivers.NewDriversMap(environment, builder.DriverConfig, fs) {
+		if err := driver.ApplyAll(stack, false); err != nil {
+			return fmt.Errorf("error running %s driver: %s", id, errors.Details(err, nil))
+		}
 	}
 
-	hash, err := repo.ResolveRevision(plumbing.Revision(target))
+	outputs, err := golden.ReadFiles(fs)
 	if err != nil {
 		return err
 	}
-
-	err = w.Checkout(&git.CheckoutOptions{
-		Hash: *hash,
-	})
-	if err != nil {
-		return err
+	// golden files are committed, secrets are masked before they are
+	// written or compared
+	for name, output := range outputs {
+		outputs[name] = redact.Bytes(output)
 	}
 
-	err = project.Update(targetDir, server)
-	if err != nil {
-		return err
-	}
-
-	targetCtx := context.Background()
-	targetCtx = context.WithValue(targetCtx, utils.ConfigDirKey, targetDir)
-	targetCtx = context.WithValue(targetCtx, utils.DryRunKey, true)
-	targetStack, _, err := buildStack(targetCtx, environment, targetDir, stackPath, buildersPath, noStrict)
-	if err != nil {
-		return err
+	if update {
+		if err := golden.Update(outputs, goldenDir); err != nil {
+			return err
+		}
+		log.Infof("📸 Updated golden files in %s", goldenDir)
+		return nil
 	}
 
-	log.Info("\n📍 Processing current stack")
-	err = project.Update(configDir, server)
+	diffs, err := golden.Check(outputs, goldenDir)
 	if err != nil {
 		return err
 	}
-	currentCtx := context.Background()
-	currentCtx = context.WithValue(currentCtx, utils.ConfigDirKey, configDir)
-	currentCtx = context.WithValue(currentCtx, utils.DryRunKey, true)
-	currentStack, _, err := buildStack(currentCtx, environment, configDir, stackPath, buildersPath, noStrict)
-	if err != nil {
-		return err
+	if len(diffs) == 0 {
+		log.Infof("👌 Output matches golden files in %s", goldenDir)
+		return nil
 	}
 
-	currentValues := utils.GetLeaves(currentStack.GetComponents(), false)
-	targetValues := utils.GetLeaves(targetStack.GetComponents(), false)
-
-	remColor := color.New(color.FgRed)
-	addColor := color.New(color.FgGreen)
-	updColor := color.New(color.FgYellow)
-	log.Info("\n🔬 Diff")
-	foundDiff := false
-	ci, ti := 0, 0
-	for ci < len(currentValues) || ti < len(targetValues) {
-		if ci == len(currentValues) {
-			tv := targetValues[ti]
-			log.Infof("\t%s %s: %s", remColor.Sprintf("-"), tv.Path, tv.Value)
-			foundDiff = true
-			ti++
-			continue
-		}
-		if ti == len(targetValues) {
-			cv := currentValues[ci]
-			log.Infof("\t%s %s: %s", addColor.Sprintf("+"), cv.Path, cv.Value)
-			foundDiff = true
-			ci++
-			continue
-		}
-
-		cv := currentValues[ci]
-		tv := targetValues[ti]
-		switch strings.Compare(cv.Path, tv.Path) {
-		case 0:
-			if strings.Compare(cv.Value, tv.Value) != 0 {
-				log.Infof("\t%s %s: %s -> %s", updColor.Sprintf("~"), cv.Path, tv.Value, cv.Value)
-				foundDiff = true
-			}
-			ci++
-			ti++
-		case -1:
-			log.Infof("\t%s %s: %s", addColor.Sprintf("+"), cv.Path, cv.Value)
-			foundDiff = true
-			ci++
-		case 1:
-			log.Infof("\t%s %s: %s", remColor.Sprintf("-"), tv.Path, tv.Value)
-			foundDiff = true
-			ti++
+	for _, fileDiff := range diffs {
+		if _, err := os.Stdout.WriteString(redact.String(fileDiff.Diff)); err != nil {
+			return err
 		}
 	}
-
-	if !foundDiff {
-		log.Infof("No changes found")
-	}
-
-	return nil
+	return fmt.Errorf("%d file(s) differ from golden files in %s, run with --update-golden to accept the changes", len(diffs), goldenDir)
 }
 
 func buildStack(ctx context.Context, environment string, configDir string, stackPath string, buildersPath string, noStrict bool) (*stack.Stack, *stackbuilder.StackBuilder, error) {
-	log.Infof("🏗️  Loading stack...")
-
 	overlays, err := utils.GetOverlays(configDir)
 	if err != nil {
 		return nil, nil, err
 	}
-	value, stackId, depIds := utils.LoadProject(configDir, &overlays)
+	return buildStackFromOverlays(ctx, environment, configDir, overlays, stackPath, buildersPath, noStrict)
+}
+
+func buildStackFromOverlays(ctx context.Context, environment string, configDir string, overlays map[string]string, stackPath string, buildersPath string, noStrict bool) (*stack.Stack, *stackbuilder.StackBuilder, error) {
+	if err := utils.ValidateEnvironment(environment); err != nil {
+		return nil, nil, err
+	}
+	log.Infof("🏗️  Loading stack...")
+
+	value, stackId, instance := utils.LoadProject(configDir, &overlays, environment)
 
 	buildSource, err := format.Node(value.Syntax(), format.Simplify())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	emptyStack := stack.Stack{
-		ID:          stackId,
-		DepIDs:      depIds,
-		BuildSource: string(buildSource),
+	emptyStack, err := stack.NewStack(value.Context().CompileString("components: {}"), stackId, instance.Deps)
+	if err != nil {
+		return nil, nil, err
 	}
-	emptyStack.AddComponents(value.Context().CompileString("{}"))
+	emptyStack.BuildSource = string(buildSource)
 
 	log.Info("👀 Validating stack...")
 	err = project.ValidateProject(value, stackPath, buildersPath, noStrict)
 	if err != nil {
-		return &emptyStack, nil, err
+		return emptyStack, nil, err
 	}
 
 	builders, err := stackbuilder.NewEnvironments(value.LookupPath(cue.ParsePath(buildersPath)))
 	if err != nil {
-		return &emptyStack, nil, err
+		return emptyStack, nil, err
 	}
 
 	builder, ok := builders[environment]
 	if !ok {
-		return &emptyStack, nil, fmt.Errorf("environment %s was not found", environment)
+		return emptyStack, nil, fmt.Errorf("environment %s was not found", environment)
+	}
+
+	policies, err := policy.LoadPolicies(value, instance.Dependencies())
+	if err != nil {
+		return emptyStack, nil, err
+	}
+	for _, p := range policies {
+		if !p.AppliesTo(environment) {
+			continue
+		}
+		log.Infof("🛡️  Enforcing policy %s", p.Name)
+		for _, transformer := range p.Pipeline {
+			builder.Flows = append(builder.Flows, stackbuilder.NewPolicyFlow(p.Name, transformer))
+		}
 	}
 
-	stack, err := stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, depIds)
+	stack, err := stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, instance.Deps)
 	if err != nil {
-		return &emptyStack, nil, err
+		return emptyStack, nil, err
 	}
 	stack.BuildSource = emptyStack.BuildSource
 
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/diff"
+	"github.com/stakpak/devx/pkg/drivers"
+	"github.com/stakpak/devx/pkg/gitrepo"
+	"github.com/stakpak/devx/pkg/golden"
+	"github.com/stakpak/devx/pkg/project"
+	"github.com/stakpak/devx/pkg/redact"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// diffSide is a transformed stack on one side of a diff
+type diffSide struct {
+	environment string
+	stack       *stack.Stack
+	builder     *stackbuilder.StackBuilder
+}
+
+func Diff(target string, environment string, configDir string, stackPath string, buildersPath string, server auth.ServerConfig, noStrict bool, artifacts bool, ignore []string, output string) error {
+	err := project.EnsureDependencies(configDir, server)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("📍 Processing target stack @ %s", target)
+	targetStack, targetBuilder, err := buildRevisionStack(target, environment, configDir, stackPath, buildersPath, server, noStrict)
+	if err != nil {
+		return err
+	}
+
+	log.Info("\n📍 Processing current stack")
+	currentCtx := context.Background()
+	currentCtx = context.WithValue(currentCtx, utils.ConfigDirKey, configDir)
+	currentCtx = context.WithValue(currentCtx, utils.DryRunKey, true)
+	currentStack, currentBuilder, err := buildStack(currentCtx, environment, configDir, stackPath, buildersPath, noStrict)
+	if err != nil {
+		return err
+	}
+
+	return printDiff(
+		target,
+		"current",
+		diffSide{environment, targetStack, targetBuilder},
+		diffSide{environment, currentStack, currentBuilder},
+		artifacts,
+		ignore,
+		output,
+	)
+}
+
+// DiffEnvironments compares the stacks of two environments at the current
+// revision, artifacts are compared relative to each driver's output directory
+func DiffEnvironments(envA string, envB string, configDir string, stackPath string, buildersPath string, server auth.ServerConfig, noStrict bool, artifacts bool, ignore []string, output string) error {
+	err := project.EnsureDependencies(configDir, server)
+	if err != nil {
+		return err
+	}
+
+	sides := []diffSide{}
+	for i, environment := range []string{envA, envB} {
+		if i > 0 {
+			log.Info()
+		}
+		log.Infof("📍 Processing environment %s", environment)
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
+		ctx = context.WithValue(ctx, utils.DryRunKey, true)
+		s, builder, err := buildStack(ctx, environment, configDir, stackPath, buildersPath, noStrict)
+		if err != nil {
+			return err
+		}
+		sides = append(sides, diffSide{environment, s, builder})
+	}
+
+	return printDiff(envA, envB, sides[0], sides[1], artifacts, ignore, output)
+}
+
+func printDiff(from string, to string, a diffSide, b diffSide, artifacts bool, ignore []string, output string) error {
+	report := diff.NewReport(a.stack.GetComponents(), b.stack.GetComponents(), ignore)
+	report.From = from
+	report.To = to
+
+	if artifacts {
+		driverArtifacts, err := diffDriverArtifacts(a, b)
+		if err != nil {
+			return err
+		}
+		// generated files have no stack paths, so secrets are masked by value
+		for i := range driverArtifacts {
+			for j := range driverArtifacts[i].Files {
+				driverArtifacts[i].Files[j].Diff = redact.String(driverArtifacts[i].Files[j].Diff)
+			}
+		}
+		report.Artifacts = driverArtifacts
+	}
+
+	// component fields are masked by path in the report, json is written as is
+	// so that value masking cannot break it
+	var w io.Writer = &redact.Writer{W: os.Stdout}
+	if output == "json" {
+		w = os.Stdout
+	}
+
+	log.Info("\n🔬 Diff")
+	if err := diff.Print(w, report, output); err != nil {
+		return err
+	}
+	if report.HasChanges() {
+		return diff.ErrChanges
+	}
+	return nil
+}
+
+// buildRevisionStack builds the stack at revision from files read out of the
+// git object store, the dependencies in cue.mod/pkg are reused when neither
+// cue.mod/module.cue nor cue.mod/devx.lock changed
+func buildRevisionStack(revision string, environment string, configDir string, stackPath string, buildersPath string, server auth.ServerConfig, noStrict bool) (*stack.Stack, *stackbuilder.StackBuilder, error) {
+	files, err := gitrepo.RevisionFiles(configDir, revision, func(name string) bool {
+		if strings.HasPrefix(name, "cue.mod/") {
+			return name == "cue.mod/module.cue" || name == "cue.mod/"+project.LockFile
+		}
+		return strings.HasSuffix(name, ".cue") || strings.HasSuffix(name, ".devx.yaml") || strings.HasSuffix(name, ".devx.yml")
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !sameRevisionFile(configDir, files, "cue.mod/module.cue") || !sameRevisionFile(configDir, files, "cue.mod/"+project.LockFile) {
+		log.Info("📦 Dependencies changed, cloning the target revision")
+		return cloneRevisionStack(revision, environment, configDir, stackPath, buildersPath, server, noStrict)
+	}
+
+	overlays, err := utils.GetRevisionOverlays(configDir, files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
+	ctx = context.WithValue(ctx, utils.DryRunKey, true)
+	return buildStackFromOverlays(ctx, environment, configDir, overlays, stackPath, buildersPath, noStrict)
+}
+
+// sameRevisionFile reports whether a file in the working tree matches the
+// file at a revision, a file missing from both matches
+func sameRevisionFile(configDir string, files map[string][]byte, name string) bool {
+	current, err := os.ReadFile(filepath.Join(configDir, filepath.FromSlash(name)))
+	target, ok := files[name]
+	if os.IsNotExist(err) && !ok {
+		return true
+	}
+	return err == nil && ok && bytes.Equal(current, target)
+}
+
+// cloneRevisionStack checks out revision in a temporary clone and fetches its
+// dependencies before building the stack
+func cloneRevisionStack(revision string, environment string, configDir string, stackPath string, buildersPath string, server auth.ServerConfig, noStrict bool) (*stack.Stack, *stackbuilder.StackBuilder, error) {
+	targetDir, err := os.MkdirTemp("", "devx-target-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(targetDir)
+
+	repo, err := git.PlainClone(targetDir, false, &git.CloneOptions{
+		URL: configDir,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = w.Checkout(&git.CheckoutOptions{
+		Hash: *hash,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = project.EnsureDependencies(targetDir, server)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, utils.ConfigDirKey, targetDir)
+	ctx = context.WithValue(ctx, utils.DryRunKey, true)
+	return buildStack(ctx, environment, targetDir, stackPath, buildersPath, noStrict)
+}
+
+// diffDriverArtifacts runs the drivers of both sides into memory and returns
+// the differences between their generated files grouped by driver
+func diffDriverArtifacts(a diffSide, b diffSide) ([]diff.DriverArtifacts, error) {
+	driverIds := []string{}
+	for id := range drivers.NewDriversMap(b.environment, b.builder.DriverConfig, memfs.New()) {
+		driverIds = append(driverIds, id)
+	}
+	sort.Strings(driverIds)
+
+	result := []diff.DriverArtifacts{}
+	for _, id := range driverIds {
+		// output directories usually contain the environment name
+		relative := a.environment != b.environment
+		aFiles, err := driverArtifacts(id, a, relative)
+		if err != nil {
+			return nil, err
+		}
+		bFiles, err := driverArtifacts(id, b, relative)
+		if err != nil {
+			return nil, err
+		}
+
+		if fileDiffs := diff.Files(aFiles, bFiles); len(fileDiffs) > 0 {
+			result = append(result, diff.DriverArtifacts{Driver: id, Files: fileDiffs})
+		}
+	}
+
+	return result, nil
+}
+
+// driverArtifacts runs a single driver into memory and returns its files,
+// relative to the driver output directory if relative is set
+func driverArtifacts(id string, side diffSide, relative bool) (map[string][]byte, error) {
+	fs := memfs.New()
+	driver := drivers.NewDriversMap(side.environment, side.builder.DriverConfig, fs)[id]
+	if err := driver.ApplyAll(side.stack, false); err != nil {
+		return nil, fmt.Errorf("error running %s driver: %s", id, errors.Details(err, nil))
+	}
+
+	files, err := golden.ReadFiles(fs)
+	if err != nil || !relative {
+		return files, err
+	}
+
+	outputDir := path.Clean(filepath.ToSlash(side.builder.DriverConfig[id].Output.Dir))
+	if outputDir == "." {
+		return files, nil
+	}
+	relativeFiles := map[string][]byte{}
+	for name, content := range files {
+		relativeFiles[strings.TrimPrefix(name, outputDir+"/")] = content
+	}
+	return relativeFiles, nil
+}
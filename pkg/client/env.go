@@ -0,0 +1,130 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/olekukonko/tablewriter"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stakpak/devx/pkg/stackbuilder"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+func EnvList(configDir string, buildersPath string) error {
+	environments, err := loadEnvironments(configDir, buildersPath, "")
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(environments))
+	for name := range environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tableData := [][]string{}
+	for _, name := range names {
+		builder := environments[name]
+
+		drivers := []string{}
+		outputs := []string{}
+		for driver, config := range builder.DriverConfig {
+			drivers = append(drivers, driver)
+			outputs = append(outputs, filepath.Join(config.Output.Dir, config.Output.File))
+		}
+		sort.Strings(drivers)
+		sort.Strings(outputs)
+
+		taskfile := "no"
+		if builder.Taskfile != nil {
+			taskfile = "yes"
+		}
+
+		tableData = append(tableData, []string{
+			name,
+			builder.Version,
+			fmt.Sprint(len(builder.Flows)),
+			strings.Join(drivers, " "),
+			strings.Join(outputs, " "),
+			taskfile,
+		})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Environment", "Version", "Flows", "Drivers", "Outputs", "Taskfile"})
+	table.SetAutoFormatHeaders(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(tableData)
+	table.Render()
+
+	return nil
+}
+
+func EnvShow(environment string, configDir string, buildersPath string, format string) error {
+	environments, err := loadEnvironments(configDir, buildersPath, environment)
+	if err != nil {
+		return err
+	}
+
+	builder, ok := environments[environment]
+	if !ok {
+		return fmt.Errorf("environment %s was not found", environment)
+	}
+
+	description, err := builder.Describe(environment)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	switch format {
+	case "yaml":
+		data, err = yaml.Marshal(description)
+	case "json":
+		data, err = json.MarshalIndent(description, "", "  ")
+		data = append(data, '\n')
+	default:
+		return fmt.Errorf("unsupported output format %s", format)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func loadEnvironments(configDir string, buildersPath string, environment string) (stackbuilder.Environments, error) {
+	if err := utils.ValidateEnvironment(environment); err != nil {
+		return nil, err
+	}
+	overlays, err := utils.GetOverlays(configDir)
+	if err != nil {
+		return nil, err
+	}
+	value, _, _ := utils.LoadProject(configDir, &overlays, environment)
+	if value.Err() != nil {
+		return nil, value.Err()
+	}
+
+	buildersValue := value.LookupPath(cue.ParsePath(buildersPath))
+	if !buildersValue.Exists() {
+		return nil, fmt.Errorf("builders field %s was not found", buildersPath)
+	}
+
+	return stackbuilder.NewEnvironments(buildersValue)
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"os"
+
+	"cuelang.org/go/cue"
+
+	"github.com/stakpak/devx/pkg/graph"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+func Graph(environment string, configDir string, stackPath string, buildersPath string, noStrict bool, format string) error {
+	var s *stack.Stack
+
+	if environment == "" {
+		overlays, err := utils.GetOverlays(configDir)
+		if err != nil {
+			return err
+		}
+		value, stackId, instance := utils.LoadProject(configDir, &overlays, "")
+		if value.Err() != nil {
+			return value.Err()
+		}
+
+		s, err = stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, instance.Deps)
+		if err != nil {
+			return err
+		}
+	} else {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
+		ctx = context.WithValue(ctx, utils.DryRunKey, true)
+
+		var err error
+		s, _, err = buildStack(ctx, environment, configDir, stackPath, buildersPath, noStrict)
+		if err != nil {
+			return err
+		}
+	}
+
+	g, err := graph.New(s)
+	if err != nil {
+		return err
+	}
+
+	data, err := g.Render(format)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write([]byte(data))
+	return err
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"cuelang.org/go/cue"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stakpak/devx/pkg/diagnostics"
+	"github.com/stakpak/devx/pkg/lint"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// Lint checks the raw stack and the stack transformed for each environment
+// against the project rules, all environments are checked if none are given
+func Lint(environments []string, configDir string, stackPath string, buildersPath string, noStrict bool, output string) error {
+	overlays, err := utils.GetOverlays(configDir)
+	if err != nil {
+		return err
+	}
+	value, stackId, instance := utils.LoadProject(configDir, &overlays, "")
+	if value.Err() != nil {
+		return value.Err()
+	}
+
+	rules, err := lint.LoadRules(value, instance.Dependencies())
+	if err != nil {
+		return err
+	}
+	log.Infof("📏 Loaded %d lint rule(s)", len(rules))
+
+	rawStack, err := stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, instance.Deps)
+	if err != nil {
+		return err
+	}
+	result := lint.Lint(rules, rawStack, "")
+	rawViolations := map[string]bool{}
+	for _, diagnostic := range result {
+		rawViolations[lintKey(diagnostic)] = true
+	}
+
+	if len(environments) == 0 {
+		buildersValue := value.LookupPath(cue.ParsePath(buildersPath))
+		if buildersValue.Exists() {
+			builders, err := stackbuilder.NewEnvironments(buildersValue)
+			if err != nil {
+				return err
+			}
+			for environment := range builders {
+				environments = append(environments, environment)
+			}
+			sort.Strings(environments)
+		}
+	}
+
+	for _, environment := range environments {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
+		ctx = context.WithValue(ctx, utils.DryRunKey, true)
+		ctx = context.WithValue(ctx, utils.AllowMissingKey, true)
+
+		transformedStack, _, err := buildStack(ctx, environment, configDir, stackPath, buildersPath, noStrict)
+		if err != nil {
+			buildDiagnostics := diagnostics.FromError(err, "build")
+			for i := range buildDiagnostics {
+				buildDiagnostics[i].Environment = environment
+			}
+			result = append(result, buildDiagnostics...)
+			continue
+		}
+		// violations already reported for the raw stack are not repeated
+		for _, diagnostic := range lint.Lint(rules, transformedStack, environment) {
+			if !rawViolations[lintKey(diagnostic)] {
+				result = append(result, diagnostic)
+			}
+		}
+	}
+
+	result.RelativeTo(configDir)
+	if err := diagnostics.Print(os.Stdout, result, output); err != nil {
+		return err
+	}
+
+	errorCount := 0
+	for _, diagnostic := range result {
+		if diagnostic.Severity == diagnostics.Error {
+			errorCount++
+		}
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("lint failed with %d error(s)", errorCount)
+	}
+	if len(result) == 0 && output == "text" {
+		log.Info("👌 All looks good")
+	}
+	return nil
+}
+
+func lintKey(diagnostic diagnostics.Diagnostic) string {
+	return fmt.Sprintf("%s/%s/%s", diagnostic.Rule, diagnostic.Component, diagnostic.Path)
+}
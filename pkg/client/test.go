@@ -0,0 +1,92 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"cuelang.org/go/cue"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stakpak/devx/pkg/policy"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+	"github.com/stakpak/devx/pkg/testrunner"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// Test runs the fixtures defined in *_test.cue files, run filters fixtures by
+// a regular expression on their name
+func Test(configDir string, buildersPath string, run string, output string) error {
+	filter, err := regexp.Compile(run)
+	if err != nil {
+		return fmt.Errorf("invalid run expression %s: %s", run, err)
+	}
+
+	overlays, err := utils.GetOverlays(configDir)
+	if err != nil {
+		return err
+	}
+	value, dependencies := utils.LoadTestProject(configDir, &overlays)
+	if value.Err() != nil {
+		return value.Err()
+	}
+
+	fixtures, err := testrunner.LoadFixtures(value)
+	if err != nil {
+		return err
+	}
+	absConfigDir, _ := filepath.Abs(configDir)
+	for _, fixture := range fixtures {
+		if file, err := filepath.Rel(absConfigDir, fixture.File); err == nil {
+			fixture.File = file
+		}
+	}
+	if len(fixtures) == 0 {
+		log.Info("No tests found in *_test.cue files")
+		return nil
+	}
+
+	runner := testrunner.Runner{
+		Builders: stackbuilder.Environments{},
+	}
+	buildersValue := value.LookupPath(cue.ParsePath(buildersPath))
+	if buildersValue.Exists() {
+		runner.Builders, err = stackbuilder.NewEnvironments(buildersValue)
+		if err != nil {
+			return err
+		}
+	}
+	runner.Policies, err = policy.LoadPolicies(value, dependencies)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, utils.ConfigDirKey, configDir)
+	ctx = context.WithValue(ctx, utils.DryRunKey, true)
+	ctx = context.WithValue(ctx, utils.AllowMissingKey, true)
+
+	results := []testrunner.Result{}
+	failed := 0
+	for _, fixture := range fixtures {
+		if !filter.MatchString(fixture.Name) {
+			continue
+		}
+		result := runner.Run(ctx, fixture)
+		if !result.Passed {
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if err := testrunner.Print(os.Stdout, results, output); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test(s) failed", failed, len(results))
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/token"
+)
+
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Note    Severity = "note"
+)
+
+type Diagnostic struct {
+	Severity    Severity `json:"severity"`
+	Rule        string   `json:"rule,omitempty"`
+	Path        string   `json:"path,omitempty"`
+	File        string   `json:"file,omitempty"`
+	Line        int      `json:"line,omitempty"`
+	Column      int      `json:"column,omitempty"`
+	Component   string   `json:"component,omitempty"`
+	Trait       string   `json:"trait,omitempty"`
+	Environment string   `json:"environment,omitempty"`
+	Message     string   `json:"message"`
+}
+
+// SetPosition attributes the diagnostic to a position in a CUE file
+func (d *Diagnostic) SetPosition(pos token.Pos) {
+	if !pos.IsValid() {
+		return
+	}
+	d.File = pos.Filename()
+	d.Line = pos.Line()
+	d.Column = pos.Column()
+}
+
+func (d Diagnostic) String() string {
+	location := ""
+	if d.File != "" {
+		location = fmt.Sprintf("%s:%d:%d: ", d.File, d.Line, d.Column)
+	}
+
+	attribution := []string{}
+	if d.Component != "" {
+		attribution = append(attribution, "component "+d.Component)
+	}
+	if d.Trait != "" {
+		attribution = append(attribution, "trait "+d.Trait)
+	}
+	if d.Environment != "" {
+		attribution = append(attribution, "environment "+d.Environment)
+	}
+
+	message := fmt.Sprintf("%s%s: %s", location, d.Severity, d.Message)
+	if d.Path != "" {
+		message = fmt.Sprintf("%s%s: %s: %s", location, d.Severity, d.Path, d.Message)
+	}
+	if len(attribution) > 0 {
+		message = fmt.Sprintf("%s (%s)", message, strings.Join(attribution, ", "))
+	}
+	return message
+}
+
+// Diagnostics is a list of diagnostics that can be returned as an error
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	lines := []string{}
+	for _, diagnostic := range d {
+		lines = append(lines, diagnostic.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (d Diagnostics) HasErrors() bool {
+	for _, diagnostic := range d {
+		if diagnostic.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders diagnostics by file, line and column
+func (d Diagnostics) Sort() {
+	sort.SliceStable(d, func(i, j int) bool {
+		if d[i].File != d[j].File {
+			return d[i].File < d[j].File
+		}
+		if d[i].Line != d[j].Line {
+			return d[i].Line < d[j].Line
+		}
+		return d[i].Column < d[j].Column
+	})
+}
+
+// RelativeTo rewrites file paths relative to dir, paths outside dir are kept
+func (d Diagnostics) RelativeTo(dir string) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+	for i := range d {
+		if d[i].File == "" || !filepath.IsAbs(d[i].File) {
+			continue
+		}
+		rel, err := filepath.Rel(absDir, d[i].File)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		d[i].File = filepath.ToSlash(rel)
+	}
+}
+
+// FromError converts an error to diagnostics, CUE errors are split and
+// attributed to their positions
+func FromError(err error, rule string) Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	var diagnostics Diagnostics
+	if errors.As(err, &diagnostics) {
+		return diagnostics
+	}
+
+	var cueErr errors.Error
+	if !errors.As(err, &cueErr) {
+		return Diagnostics{{
+			Severity: Error,
+			Rule:     rule,
+			Message:  err.Error(),
+		}}
+	}
+
+	result := Diagnostics{}
+	for _, e := range errors.Errors(err) {
+		format, args := e.Msg()
+		diagnostic := Diagnostic{
+			Severity: Error,
+			Rule:     rule,
+			Path:     strings.Join(e.Path(), "."),
+			Message:  fmt.Sprintf(format, args...),
+		}
+		diagnostic.SetPosition(e.Position())
+		if diagnostic.File == "" {
+			for _, pos := range e.InputPositions() {
+				diagnostic.SetPosition(pos)
+				if diagnostic.File != "" {
+					break
+				}
+			}
+		}
+		result = append(result, diagnostic)
+	}
+	return result
+}
+
+// Print writes diagnostics in one of the formats text, json or sarif
+func Print(w io.Writer, diagnostics Diagnostics, format string) error {
+	switch format {
+	case "text":
+		for _, diagnostic := range diagnostics {
+			if _, err := fmt.Fprintln(w, diagnostic.String()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "json":
+		if diagnostics == nil {
+			diagnostics = Diagnostics{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diagnostics)
+	case "sarif":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(toSARIF(diagnostics))
+	}
+	return fmt.Errorf("unsupported output format %s", format)
+}
@@ -0,0 +1,87 @@
+package diagnostics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestFromError(t *testing.T) {
+	value := cuecontext.New().CompileString(`
+stack: components: app: {
+	port: 80
+	port: 8080
+}
+`, cue.Filename("stack.cue"))
+
+	result := FromError(value.Validate(), "cue")
+	if len(result) != 1 {
+		t.Fatalf("Expected 1 diagnostic but found %d: %s", len(result), result)
+	}
+	diagnostic := result[0]
+	if diagnostic.File != "stack.cue" || diagnostic.Line == 0 {
+		t.Errorf("Expected a position in stack.cue but found %s:%d", diagnostic.File, diagnostic.Line)
+	}
+	if diagnostic.Path != "stack.components.app.port" {
+		t.Errorf("Expected path stack.components.app.port but found %s", diagnostic.Path)
+	}
+
+	result = FromError(errors.New("something failed"), "stack")
+	if len(result) != 1 || result[0].Message != "something failed" || result[0].Rule != "stack" {
+		t.Errorf("Unexpected diagnostics %v", result)
+	}
+
+	if FromError(nil, "cue") != nil {
+		t.Error("Expected no diagnostics for a nil error")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	result := Diagnostics{
+		{
+			Severity:    Error,
+			Rule:        "trait-unfulfilled",
+			File:        "stack.cue",
+			Line:        3,
+			Column:      2,
+			Component:   "app",
+			Trait:       "Workload",
+			Environment: "dev",
+			Message:     "trait Workload is not fulfilled",
+		},
+	}
+
+	text := bytes.Buffer{}
+	if err := Print(&text, result, "text"); err != nil {
+		t.Fatal(err)
+	}
+	expected := "stack.cue:3:2: error: trait Workload is not fulfilled (component app, trait Workload, environment dev)\n"
+	if text.String() != expected {
+		t.Errorf("Expected %q but found %q", expected, text.String())
+	}
+
+	sarif := bytes.Buffer{}
+	if err := Print(&sarif, result, "sarif"); err != nil {
+		t.Fatal(err)
+	}
+	log := sarifLog{}
+	if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("Expected 1 sarif result but found %s", sarif.String())
+	}
+	location := log.Runs[0].Results[0].Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "stack.cue" || location.Region.StartLine != 3 {
+		t.Errorf("Unexpected sarif location %+v", location)
+	}
+
+	if err := Print(&bytes.Buffer{}, result, "xml"); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Expected unsupported format error but found %v", err)
+	}
+}
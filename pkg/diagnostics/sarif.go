@@ -0,0 +1,147 @@
+package diagnostics
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	defaultRule  = "devx"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func toSARIF(diagnostics Diagnostics) sarifLog {
+	rules := map[string]bool{}
+	results := []sarifResult{}
+	for _, diagnostic := range diagnostics {
+		ruleID := diagnostic.Rule
+		if ruleID == "" {
+			ruleID = defaultRule
+		}
+		rules[ruleID] = true
+
+		message := diagnostic.Message
+		if diagnostic.Path != "" {
+			message = fmt.Sprintf("%s: %s", diagnostic.Path, message)
+		}
+
+		result := sarifResult{
+			RuleID:  ruleID,
+			Level:   string(diagnostic.Severity),
+			Message: sarifMessage{Text: message},
+		}
+		if diagnostic.File != "" {
+			location := sarifLocation{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: diagnostic.File},
+				},
+			}
+			if diagnostic.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   diagnostic.Line,
+					StartColumn: diagnostic.Column,
+				}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+
+		properties := map[string]interface{}{}
+		if diagnostic.Component != "" {
+			properties["component"] = diagnostic.Component
+		}
+		if diagnostic.Trait != "" {
+			properties["trait"] = diagnostic.Trait
+		}
+		if diagnostic.Environment != "" {
+			properties["environment"] = diagnostic.Environment
+		}
+		if len(properties) > 0 {
+			result.Properties = properties
+		}
+
+		results = append(results, result)
+	}
+
+	ruleIDs := []string{}
+	for id := range rules {
+		ruleIDs = append(ruleIDs, id)
+	}
+	sort.Strings(ruleIDs)
+	sarifRules := []sarifRule{}
+	for _, id := range ruleIDs {
+		sarifRules = append(sarifRules, sarifRule{ID: id})
+	}
+
+	return sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{
+			{
+				Tool: sarifTool{
+					Driver: sarifDriver{
+						Name:           "devx",
+						InformationURI: "https://devx.stakpak.dev",
+						Rules:          sarifRules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+}
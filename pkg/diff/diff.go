@@ -0,0 +1,41 @@
+package diff
+
+import (
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Unified returns a unified diff between a and b, empty if they are equal
+func Unified(a string, b string, fromFile string, toFile string) string {
+	if a == b {
+		return ""
+	}
+
+	result, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	if err != nil {
+		return err.Error()
+	}
+	return result
+}
+
+// splitLines splits s keeping line endings and terminates the last line so
+// that files without a trailing newline still produce a readable diff
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] += "\n"
+	}
+	return lines
+}
@@ -0,0 +1,73 @@
+package diff
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+func TestUnified(t *testing.T) {
+	if result := Unified("a\nb\n", "a\nb\n", "expected", "actual"); result != "" {
+		t.Errorf("Expected no diff but found %s", result)
+	}
+
+	expected := `--- expected
++++ actual
+@@ -1,2 +1,2 @@
+ a
+-b
++c
+`
+	if result := Unified("a\nb", "a\nc\n", "expected", "actual"); result != expected {
+		t.Errorf("Expected:\n%s\nbut found:\n%s", expected, result)
+	}
+}
+
+func TestLeaves(t *testing.T) {
+	a := []utils.Leaf{
+		{Path: "app.$resources.app.namespace", Value: `"staging"`},
+		{Path: "app.image", Value: `"app:1"`},
+		{Path: "app.ports[0]", Value: "80"},
+		{Path: "db.host", Value: `"db"`},
+	}
+	b := []utils.Leaf{
+		{Path: "app.$resources.app.namespace", Value: `"prod"`},
+		{Path: "app.image", Value: `"app:2"`},
+		{Path: "app.ports[0]", Value: "80"},
+		{Path: "cache.host", Value: `"cache"`},
+	}
+
+	changes := Leaves(a, b, []string{"*.$resources.*.namespace"})
+	expected := []LeafChange{
+		{Path: "app.image", Status: Modified, Before: `"app:1"`, After: `"app:2"`},
+		{Path: "cache.host", Status: Added, After: `"cache"`},
+		{Path: "db.host", Status: Removed, Before: `"db"`},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf("Expected %v but found %v", expected, changes)
+	}
+}
+
+func TestIsIgnored(t *testing.T) {
+	tests := []struct {
+		path     string
+		patterns []string
+		ignored  bool
+	}{
+		{"app.$resources.app.namespace", []string{"*.$resources.*.namespace"}, true},
+		{"app.$resources.app.metadata.namespace", []string{"*.$resources.*.namespace"}, false},
+		{"app.$resources.app.metadata.namespace", []string{"**.namespace"}, true},
+		{"app.env.DB_HOST", []string{"app.env"}, true},
+		{`app.env."db.host"`, []string{"app.env.db.host"}, false},
+		{`app.env."db.host"`, []string{`app.env."db.host"`}, true},
+		{"app.ports[1].port", []string{"app.ports.*.port"}, true},
+		{"app.image", []string{"app.images"}, false},
+	}
+
+	for _, test := range tests {
+		if result := IsIgnored(test.path, test.patterns); result != test.ignored {
+			t.Errorf("Expected IsIgnored(%s, %v) to be %v", test.path, test.patterns, test.ignored)
+		}
+	}
+}
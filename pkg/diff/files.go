@@ -0,0 +1,64 @@
+package diff
+
+import (
+	"bytes"
+	"sort"
+)
+
+type Status string
+
+const (
+	Added    Status = "added"
+	Removed  Status = "removed"
+	Modified Status = "modified"
+	Renamed  Status = "renamed"
+)
+
+type FileDiff struct {
+	Path   string `json:"path"`
+	Status Status `json:"status"`
+	Diff   string `json:"diff"`
+}
+
+// Files compares two file trees keyed by path and returns the files that were
+// added, removed or modified in b, sorted by path
+func Files(a map[string][]byte, b map[string][]byte) []FileDiff {
+	paths := []string{}
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if _, ok := a[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	result := []FileDiff{}
+	for _, p := range paths {
+		before, inA := a[p]
+		after, inB := b[p]
+
+		switch {
+		case !inA:
+			result = append(result, FileDiff{
+				Path:   p,
+				Status: Added,
+				Diff:   Unified("", string(after), "/dev/null", "b/"+p),
+			})
+		case !inB:
+			result = append(result, FileDiff{
+				Path:   p,
+				Status: Removed,
+				Diff:   Unified(string(before), "", "a/"+p, "/dev/null"),
+			})
+		case !bytes.Equal(before, after):
+			result = append(result, FileDiff{
+				Path:   p,
+				Status: Modified,
+				Diff:   Unified(string(before), string(after), "a/"+p, "b/"+p),
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,130 @@
+package diff
+
+import (
+	"path"
+	"strings"
+
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+type LeafChange struct {
+	Path        string `json:"path"`
+	Status      Status `json:"status"`
+	Before      string `json:"before,omitempty"`
+	After       string `json:"after,omitempty"`
+	Destructive bool   `json:"destructive,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Leaves compares two lists of leaves sorted by path and returns the leaves
+// that were added, removed or modified in b. Leaves matching one of the
+// ignore patterns are skipped
+func Leaves(a []utils.Leaf, b []utils.Leaf, ignore []string) []LeafChange {
+	result := []LeafChange{}
+	add := func(change LeafChange) {
+		if !IsIgnored(change.Path, ignore) {
+			result = append(result, change)
+		}
+	}
+
+	ai, bi := 0, 0
+	for ai < len(a) || bi < len(b) {
+		if bi == len(b) {
+			add(LeafChange{Path: a[ai].Path, Status: Removed, Before: a[ai].Value})
+			ai++
+			continue
+		}
+		if ai == len(a) {
+			add(LeafChange{Path: b[bi].Path, Status: Added, After: b[bi].Value})
+			bi++
+			continue
+		}
+
+		switch strings.Compare(a[ai].Path, b[bi].Path) {
+		case 0:
+			if a[ai].Value != b[bi].Value {
+				add(LeafChange{Path: a[ai].Path, Status: Modified, Before: a[ai].Value, After: b[bi].Value})
+			}
+			ai++
+			bi++
+		case -1:
+			add(LeafChange{Path: a[ai].Path, Status: Removed, Before: a[ai].Value})
+			ai++
+		case 1:
+			add(LeafChange{Path: b[bi].Path, Status: Added, After: b[bi].Value})
+			bi++
+		}
+	}
+
+	return result
+}
+
+// IsIgnored checks if a leaf path or any of its parents matches one of the
+// patterns, patterns are dot separated where each part is a glob matching a
+// single label or list index and ** matches any number of labels
+//
+//	*.$resources.*.namespace
+//	**.labels
+func IsIgnored(leafPath string, patterns []string) bool {
+	labels := splitPath(leafPath)
+	for _, pattern := range patterns {
+		if matchLabels(splitPath(pattern), labels) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchLabels(pattern []string, labels []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(labels); i++ {
+			if matchLabels(pattern[1:], labels[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(labels) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], labels[0]); !ok {
+		return false
+	}
+	return matchLabels(pattern[1:], labels[1:])
+}
+
+// splitPath splits a CUE path on dots and list indices outside of quoted
+// labels, quotes are removed from labels and ports[0] is split into ports, 0
+func splitPath(p string) []string {
+	labels := []string{}
+	label := strings.Builder{}
+	quoted := false
+	flush := func() {
+		if label.Len() > 0 {
+			labels = append(labels, label.String())
+			label.Reset()
+		}
+	}
+
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case c == '\\' && quoted && i+1 < len(p):
+			i++
+			label.WriteByte(p[i])
+		case c == '"':
+			quoted = !quoted
+		case c == '.' && !quoted:
+			flush()
+		case (c == '[' || c == ']') && !quoted:
+			flush()
+		default:
+			label.WriteByte(c)
+		}
+	}
+	flush()
+	return labels
+}
@@ -0,0 +1,189 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Print writes a report in one of the formats text, json or markdown
+func Print(w io.Writer, report Report, format string) error {
+	switch format {
+	case "text":
+		return printText(w, report)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "markdown":
+		return printMarkdown(w, report)
+	}
+	return fmt.Errorf("unsupported output format %s", format)
+}
+
+func printText(w io.Writer, report Report) error {
+	remColor := color.New(color.FgRed)
+	addColor := color.New(color.FgGreen)
+	updColor := color.New(color.FgYellow)
+	symbols := map[Status]string{
+		Added:    addColor.Sprint("+"),
+		Removed:  remColor.Sprint("-"),
+		Modified: updColor.Sprint("~"),
+		Renamed:  updColor.Sprint("»"),
+	}
+	warning := func(destructive bool, reason string) string {
+		if !destructive || reason == "" {
+			return ""
+		}
+		return remColor.Sprintf(" ⚠️  %s", reason)
+	}
+	printField := func(prefix string, field LeafChange) {
+		switch field.Status {
+		case Added:
+			fmt.Fprintf(w, "\t%s %s.%s: %s\n", symbols[Added], prefix, field.Path, field.After)
+		case Removed:
+			fmt.Fprintf(w, "\t%s %s.%s: %s%s\n", symbols[Removed], prefix, field.Path, field.Before, warning(field.Destructive, field.Reason))
+		case Modified:
+			fmt.Fprintf(w, "\t%s %s.%s: %s -> %s%s\n", symbols[Modified], prefix, field.Path, field.Before, field.After, warning(field.Destructive, field.Reason))
+		}
+	}
+
+	for _, component := range report.Components {
+		switch component.Status {
+		case Added, Removed:
+			fmt.Fprintf(w, "\t%s %s%s\n", symbols[component.Status], component.Component, warning(component.Destructive, component.Reason))
+			continue
+		case Renamed:
+			fmt.Fprintf(w, "\t%s %s -> %s%s\n", symbols[Renamed], component.From, component.Component, warning(component.Destructive, component.Reason))
+		}
+		for _, field := range component.Fields {
+			printField(component.Component, field)
+		}
+		for _, resource := range component.Resources {
+			prefix := fmt.Sprintf("%s.$resources.%s", component.Component, resource.Resource)
+			if resource.Status != Modified {
+				fmt.Fprintf(w, "\t%s %s (%s)%s\n", symbols[resource.Status], prefix, resource.Kind, warning(resource.Destructive, resource.Reason))
+				continue
+			}
+			for _, field := range resource.Fields {
+				printField(prefix, field)
+			}
+		}
+	}
+
+	for _, driver := range report.Artifacts {
+		fmt.Fprintf(w, "\n[%s]\n", driver.Driver)
+		for _, file := range driver.Files {
+			fmt.Fprintf(w, "\t%s %s\n", symbols[file.Status], file.Path)
+		}
+		for _, file := range driver.Files {
+			fmt.Fprint(w, file.Diff)
+		}
+	}
+
+	if !report.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes found")
+		return err
+	}
+	return nil
+}
+
+func printMarkdown(w io.Writer, report Report) error {
+	fmt.Fprintf(w, "### devx diff `%s` → `%s`\n\n", report.From, report.To)
+	if !report.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes found")
+		return err
+	}
+
+	destructive := 0
+	for _, component := range report.Components {
+		if component.Destructive {
+			destructive++
+		}
+	}
+	if destructive > 0 {
+		fmt.Fprintf(w, "> ⚠️ **%d component(s) with destructive changes**\n\n", destructive)
+	}
+
+	if len(report.Components) > 0 {
+		fmt.Fprintln(w, "| Component | Resource | Kind | Change | Path | Before | After |")
+		fmt.Fprintln(w, "|---|---|---|---|---|---|---|")
+	}
+	row := func(component string, resource string, kind string, status Status, destructive bool, reason string, path string, before string, after string) {
+		change := string(status)
+		if destructive {
+			change = fmt.Sprintf("⚠️ %s", reason)
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s |\n",
+			markdownCell(component, false),
+			markdownCell(resource, false),
+			markdownCell(kind, false),
+			change,
+			markdownCell(path, true),
+			markdownCell(before, true),
+			markdownCell(after, true),
+		)
+	}
+	for _, component := range report.Components {
+		switch component.Status {
+		case Added, Removed:
+			row(component.Component, "", "", component.Status, component.Destructive, component.Reason, "", "", "")
+			continue
+		case Renamed:
+			row(component.Component, "", "", component.Status, component.Destructive, component.Reason, "", component.From, component.Component)
+		}
+		for _, field := range component.Fields {
+			row(component.Component, "", "", field.Status, field.Destructive, field.Reason, field.Path, field.Before, field.After)
+		}
+		for _, resource := range component.Resources {
+			if resource.Status != Modified {
+				row(component.Component, resource.Resource, resource.Kind, resource.Status, resource.Destructive, resource.Reason, "", "", "")
+				continue
+			}
+			for _, field := range resource.Fields {
+				row(component.Component, resource.Resource, resource.Kind, field.Status, field.Destructive, field.Reason, field.Path, field.Before, field.After)
+			}
+		}
+	}
+
+	for _, driver := range report.Artifacts {
+		for _, file := range driver.Files {
+			fmt.Fprintf(w, "\n<details><summary>%s: <code>%s</code> (%s)</summary>\n\n```diff\n%s```\n\n</details>\n", driver.Driver, file.Path, file.Status, file.Diff)
+		}
+	}
+	return nil
+}
+
+// markdownCell escapes a table cell, code cells are wrapped in a backtick
+// fence longer than any run of backticks in the value
+func markdownCell(s string, code bool) string {
+	if s == "" {
+		return ""
+	}
+	s = strings.ReplaceAll(s, "|", "\\|")
+	s = strings.ReplaceAll(s, "\n", " ")
+	if !code {
+		return s
+	}
+
+	longest, run := 0, 0
+	for _, c := range s {
+		if c == '`' {
+			run++
+			if run > longest {
+				longest = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", longest+1)
+	// one space is stripped from both ends of padded code spans
+	if strings.HasPrefix(s, "`") || strings.HasSuffix(s, "`") || strings.HasPrefix(s, " ") || strings.HasSuffix(s, " ") {
+		s = " " + s + " "
+	}
+	return fence + s + fence
+}
@@ -0,0 +1,301 @@
+package diff
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/redact"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// ErrChanges is returned when a diff found changes so that callers can exit
+// with a distinct code
+var ErrChanges = errors.New("changes found")
+
+const (
+	reasonComponentRemoved = "component removed"
+	reasonComponentRenamed = "component renamed"
+	reasonResourceRemoved  = "resource removed"
+	reasonImageChanged     = "image changed"
+	reasonVolumeChanged    = "volume changed"
+)
+
+type Report struct {
+	From        string            `json:"from"`
+	To          string            `json:"to"`
+	Destructive bool              `json:"destructive"`
+	Components  []ComponentChange `json:"components"`
+	Artifacts   []DriverArtifacts `json:"artifacts,omitempty"`
+}
+
+type ComponentChange struct {
+	Component   string           `json:"component"`
+	From        string           `json:"from,omitempty"`
+	Status      Status           `json:"status"`
+	Destructive bool             `json:"destructive,omitempty"`
+	Reason      string           `json:"reason,omitempty"`
+	Fields      []LeafChange     `json:"fields,omitempty"`
+	Resources   []ResourceChange `json:"resources,omitempty"`
+}
+
+type ResourceChange struct {
+	Resource    string       `json:"resource"`
+	Kind        string       `json:"kind"`
+	Status      Status       `json:"status"`
+	Destructive bool         `json:"destructive,omitempty"`
+	Reason      string       `json:"reason,omitempty"`
+	Fields      []LeafChange `json:"fields,omitempty"`
+}
+
+type DriverArtifacts struct {
+	Driver string     `json:"driver"`
+	Files  []FileDiff `json:"files"`
+}
+
+func (r Report) HasChanges() bool {
+	return len(r.Components) > 0 || len(r.Artifacts) > 0
+}
+
+// NewReport classifies the changes between two stacks by component and
+// resource, a and b are the components of each stack. A removed component that
+// is similar enough to an added one is reported as a rename. Removed or
+// renamed components, removed resources, changed images and changed volumes
+// are flagged as destructive
+func NewReport(a cue.Value, b cue.Value, ignore []string) Report {
+	report := Report{
+		Components: []ComponentChange{},
+	}
+
+	aComponents := fieldValues(a)
+	bComponents := fieldValues(b)
+	ids := []string{}
+	for _, id := range unionKeys(aComponents, bComponents) {
+		if !IsIgnored(id, ignore) {
+			ids = append(ids, id)
+		}
+	}
+
+	removed := map[string][]utils.Leaf{}
+	added := map[string][]utils.Leaf{}
+	for _, id := range ids {
+		aComponent, inA := aComponents[id]
+		bComponent, inB := bComponents[id]
+		switch {
+		case !inA:
+			added[id] = Flatten(bComponent)
+		case !inB:
+			removed[id] = Flatten(aComponent)
+		}
+	}
+	renames := matchRenames(removed, added)
+	renamed := map[string]bool{}
+	for _, from := range renames {
+		renamed[from] = true
+	}
+
+	for _, id := range ids {
+		aComponent, inA := aComponents[id]
+		bComponent, inB := bComponents[id]
+
+		var change ComponentChange
+		switch {
+		case !inA:
+			from, ok := renames[id]
+			if !ok {
+				change = ComponentChange{Component: id, Status: Added}
+				break
+			}
+			change = compareComponents(id, aComponents[from], bComponent, ignore)
+			change.Status = Renamed
+			change.From = from
+			change.Destructive = true
+			change.Reason = reasonComponentRenamed
+		case !inB:
+			if renamed[id] {
+				continue
+			}
+			change = ComponentChange{Component: id, Status: Removed, Destructive: true, Reason: reasonComponentRemoved}
+		default:
+			change = compareComponents(id, aComponent, bComponent, ignore)
+			if len(change.Fields) == 0 && len(change.Resources) == 0 {
+				continue
+			}
+		}
+
+		report.Destructive = report.Destructive || change.Destructive
+		report.Components = append(report.Components, change)
+	}
+
+	return report
+}
+
+// compareComponents compares two versions of a component, id is the component
+// id in b used to match ignore patterns and sensitive paths
+func compareComponents(id string, a cue.Value, b cue.Value, ignore []string) ComponentChange {
+	change := ComponentChange{
+		Component: id,
+		Status:    Modified,
+		Resources: resourceChanges(id, a, b, ignore),
+	}
+
+	for _, field := range Leaves(Flatten(a), Flatten(b), nil) {
+		fullPath := id + "." + field.Path
+		if IsIgnored(fullPath, ignore) {
+			continue
+		}
+		field.Destructive, field.Reason = classifyLeaf(field)
+		if redact.IsSensitivePath(fullPath) {
+			field.Before, field.After = maskValue(field.Before), maskValue(field.After)
+		}
+
+		if strings.HasPrefix(field.Path, "$resources.") {
+			resourceId, fieldPath := splitFirst(strings.TrimPrefix(field.Path, "$resources."))
+			resource := findResource(&change, resourceId)
+			if resource.Status != Modified {
+				continue
+			}
+			field.Path = fieldPath
+			resource.Fields = append(resource.Fields, field)
+			continue
+		}
+		change.Fields = append(change.Fields, field)
+	}
+
+	resources := []ResourceChange{}
+	for _, resource := range change.Resources {
+		if resource.Status == Modified && len(resource.Fields) == 0 {
+			continue
+		}
+		for _, field := range resource.Fields {
+			resource.Destructive = resource.Destructive || field.Destructive
+		}
+		change.Destructive = change.Destructive || resource.Destructive
+		resources = append(resources, resource)
+	}
+	change.Resources = resources
+	for _, field := range change.Fields {
+		change.Destructive = change.Destructive || field.Destructive
+	}
+
+	return change
+}
+
+// resourceChanges lists the resources added to or removed from a component
+// and a placeholder for each resource found in both, fields are added later
+func resourceChanges(componentId string, a cue.Value, b cue.Value, ignore []string) []ResourceChange {
+	resourcesPath := cue.ParsePath("$resources")
+	aResources := fieldValues(a.LookupPath(resourcesPath))
+	bResources := fieldValues(b.LookupPath(resourcesPath))
+
+	result := []ResourceChange{}
+	for _, id := range unionKeys(aResources, bResources) {
+		if IsIgnored(strings.Join([]string{componentId, "$resources", id}, "."), ignore) {
+			continue
+		}
+		aResource, inA := aResources[id]
+		bResource, inB := bResources[id]
+		switch {
+		case !inA:
+			result = append(result, ResourceChange{Resource: id, Kind: resourceKind(bResource), Status: Added})
+		case !inB:
+			result = append(result, ResourceChange{Resource: id, Kind: resourceKind(aResource), Status: Removed, Destructive: true, Reason: reasonResourceRemoved})
+		default:
+			result = append(result, ResourceChange{Resource: id, Kind: resourceKind(bResource), Status: Modified})
+		}
+	}
+	return result
+}
+
+func findResource(component *ComponentChange, id string) *ResourceChange {
+	for i := range component.Resources {
+		if component.Resources[i].Resource == id {
+			return &component.Resources[i]
+		}
+	}
+	// resources matching an ignore pattern are not listed
+	return &ResourceChange{Status: Removed}
+}
+
+// resourceKind is the driver of a resource followed by its kind for
+// resources that declare one like kubernetes manifests
+func resourceKind(resource cue.Value) string {
+	driver, _ := resource.LookupPath(cue.ParsePath("$metadata.labels.driver")).String()
+	kind, err := resource.LookupPath(cue.ParsePath("kind")).String()
+	if err != nil || kind == "" {
+		return driver
+	}
+	if driver == "" {
+		return kind
+	}
+	return driver + "/" + kind
+}
+
+func classifyLeaf(change LeafChange) (bool, string) {
+	if change.Status == Added {
+		return false, ""
+	}
+	labels := splitPath(change.Path)
+	if len(labels) > 0 && labels[len(labels)-1] == "image" {
+		return true, reasonImageChanged
+	}
+	for _, label := range labels {
+		if strings.Contains(strings.ToLower(label), "volume") {
+			return true, reasonVolumeChanged
+		}
+	}
+	return false, ""
+}
+
+// splitFirst splits the first label off a path keeping its quotes, list
+// elements stay attached to the rest of the path
+func splitFirst(p string) (string, string) {
+	quoted := false
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == '\\' && quoted:
+			i++
+		case p[i] == '"':
+			quoted = !quoted
+		case p[i] == '.' && !quoted:
+			return p[:i], p[i+1:]
+		case p[i] == '[' && !quoted:
+			return p[:i], p[i:]
+		}
+	}
+	return p, ""
+}
+
+func maskValue(value string) string {
+	if value == "" {
+		return value
+	}
+	return redact.Mask
+}
+
+func fieldValues(value cue.Value) map[string]cue.Value {
+	result := map[string]cue.Value{}
+	fieldIter, err := value.Fields()
+	if err != nil {
+		return result
+	}
+	for fieldIter.Next() {
+		result[fieldIter.Selector().String()] = fieldIter.Value()
+	}
+	return result
+}
+
+func unionKeys(a map[string]cue.Value, b map[string]cue.Value) []string {
+	keys := []string{}
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,129 @@
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func compileComponents(t *testing.T, src string) cue.Value {
+	value := cuecontext.New().CompileString(src)
+	if value.Err() != nil {
+		t.Fatal(value.Err())
+	}
+	return value.LookupPath(cue.ParsePath("stack.components"))
+}
+
+func TestNewReport(t *testing.T) {
+	a := compileComponents(t, `
+stack: components: {
+	app: {
+		tier: "small"
+		$resources: {
+			deployment: {
+				$metadata: labels: driver: "kubernetes"
+				kind: "Deployment"
+				spec: image: "app:1"
+				spec: namespace: "staging"
+			}
+			config: {
+				$metadata: labels: driver: "kubernetes"
+				kind: "ConfigMap"
+			}
+		}
+	}
+	db: host: "db"
+}
+`)
+	b := compileComponents(t, `
+stack: components: {
+	app: {
+		tier: "large"
+		$resources: {
+			deployment: {
+				$metadata: labels: driver: "kubernetes"
+				kind: "Deployment"
+				spec: image: "app:2"
+				spec: namespace: "prod"
+			}
+		}
+	}
+	cache: host: "cache"
+}
+`)
+
+	report := NewReport(a, b, []string{"*.$resources.*.spec.namespace"})
+	if !report.Destructive {
+		t.Errorf("Expected report to be destructive")
+	}
+	if len(report.Components) != 3 {
+		t.Fatalf("Expected 3 changed components but found %d", len(report.Components))
+	}
+
+	app := report.Components[0]
+	if app.Component != "app" || app.Status != Modified || !app.Destructive {
+		t.Errorf("Expected app to be modified and destructive but found %+v", app)
+	}
+	if len(app.Fields) != 1 || app.Fields[0].Path != "tier" || app.Fields[0].Destructive {
+		t.Errorf("Expected a non destructive tier change but found %+v", app.Fields)
+	}
+	if len(app.Resources) != 2 {
+		t.Fatalf("Expected 2 changed resources but found %+v", app.Resources)
+	}
+	if config := app.Resources[0]; config.Resource != "config" || config.Status != Removed || config.Kind != "kubernetes/ConfigMap" || config.Reason != reasonResourceRemoved {
+		t.Errorf("Expected removed config map but found %+v", config)
+	}
+	deployment := app.Resources[1]
+	if len(deployment.Fields) != 1 || deployment.Fields[0].Path != "spec.image" || deployment.Fields[0].Reason != reasonImageChanged {
+		t.Errorf("Expected only an image change in deployment but found %+v", deployment.Fields)
+	}
+
+	if cache := report.Components[1]; cache.Component != "cache" || cache.Status != Added || cache.Destructive {
+		t.Errorf("Expected added cache but found %+v", cache)
+	}
+	if db := report.Components[2]; db.Component != "db" || db.Status != Removed || db.Reason != reasonComponentRemoved {
+		t.Errorf("Expected removed db but found %+v", db)
+	}
+}
+
+func TestPrint(t *testing.T) {
+	value := compileComponents(t, `stack: components: app: volumes: data: size: "1Gi"`)
+	changed := compileComponents(t, `stack: components: app: volumes: data: size: "2Gi"`)
+
+	report := NewReport(value, changed, nil)
+	report.From = "main"
+	report.To = "current"
+
+	buf := bytes.Buffer{}
+	if err := Print(&buf, report, "markdown"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| app |  |  | ⚠️ volume changed | `volumes.data.size` | `\"1Gi\"` | `\"2Gi\"` |") {
+		t.Errorf("Expected a destructive volume row but found:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := Print(&buf, NewReport(value, value, nil), "text"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "No changes found\n" {
+		t.Errorf("Expected no changes but found %s", buf.String())
+	}
+}
+
+func TestMarkdownCell(t *testing.T) {
+	for value, expected := range map[string]string{
+		`"2Gi"`:     "`\"2Gi\"`",
+		"a|b":       "`a\\|b`",
+		"echo `id`": "`` echo `id` ``",
+		"a ``` b":   "````a ``` b````",
+		" a":        "`  a `",
+	} {
+		if result := markdownCell(value, true); result != expected {
+			t.Errorf("Expected %s to be formatted as %s but found %s", value, expected, result)
+		}
+	}
+}
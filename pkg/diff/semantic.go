@@ -0,0 +1,173 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// identityKeys are fields that identify list elements, checked in order
+var identityKeys = []string{"name", "port", "key"}
+
+// renameThreshold is the minimum share of leaves a removed and an added
+// component must have in common to be reported as a rename
+const renameThreshold = 0.5
+
+// Flatten returns the concrete leaves of value sorted by path relative to
+// value, $metadata fields are skipped and defaults are resolved. List elements
+// are labelled by an identity key when all elements have a unique one, e.g.
+// ports[port=80] or env[name="DB_HOST"], so that inserting an element does
+// not shift the elements after it
+func Flatten(value cue.Value) []utils.Leaf {
+	result := []utils.Leaf{}
+	flattenInto(value, "", &result)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result
+}
+
+func flattenInto(value cue.Value, prefix string, result *[]utils.Leaf) {
+	value, _ = value.Default()
+
+	switch value.Kind() {
+	case cue.StructKind:
+		fieldIter, err := value.Fields()
+		if err != nil {
+			return
+		}
+		for fieldIter.Next() {
+			label := fieldIter.Selector().String()
+			if label == "$metadata" {
+				continue
+			}
+			if prefix != "" {
+				label = prefix + "." + label
+			}
+			flattenInto(fieldIter.Value(), label, result)
+		}
+	case cue.ListKind:
+		elements := []cue.Value{}
+		listIter, err := value.List()
+		if err != nil {
+			return
+		}
+		for listIter.Next() {
+			elements = append(elements, listIter.Value())
+		}
+		for i, label := range elementLabels(elements) {
+			flattenInto(elements[i], prefix+label, result)
+		}
+	case cue.BoolKind, cue.IntKind, cue.FloatKind, cue.NumberKind, cue.StringKind, cue.BytesKind:
+		*result = append(*result, utils.Leaf{
+			Path:  prefix,
+			Value: fmt.Sprint(value),
+		})
+	}
+}
+
+func elementLabels(elements []cue.Value) []string {
+	for _, key := range identityKeys {
+		if labels := identityLabels(elements, key); labels != nil {
+			return labels
+		}
+	}
+
+	labels := []string{}
+	for i := range elements {
+		labels = append(labels, fmt.Sprintf("[%d]", i))
+	}
+	return labels
+}
+
+// identityLabels labels elements by key, nil if any element lacks a concrete
+// string or int key or if keys are not unique
+func identityLabels(elements []cue.Value, key string) []string {
+	if len(elements) == 0 {
+		return nil
+	}
+
+	labels := []string{}
+	seen := map[string]bool{}
+	for _, element := range elements {
+		if element.Kind() != cue.StructKind {
+			return nil
+		}
+		id, _ := element.LookupPath(cue.MakePath(cue.Str(key))).Default()
+		if kind := id.Kind(); kind != cue.StringKind && kind != cue.IntKind {
+			return nil
+		}
+
+		label := fmt.Sprintf("[%s=%v]", key, id)
+		if seen[label] {
+			return nil
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// similarity is the share of leaves two components have in common
+func similarity(a []utils.Leaf, b []utils.Leaf) float64 {
+	total := len(a)
+	if len(b) > total {
+		total = len(b)
+	}
+	if total == 0 {
+		return 0
+	}
+
+	values := map[string]string{}
+	for _, leaf := range a {
+		values[leaf.Path] = leaf.Value
+	}
+	common := 0
+	for _, leaf := range b {
+		if value, ok := values[leaf.Path]; ok && value == leaf.Value {
+			common++
+		}
+	}
+	return float64(common) / float64(total)
+}
+
+// matchRenames pairs removed with added components by similarity, the most
+// similar pairs are matched first. It returns the removed id of each added id
+func matchRenames(removed map[string][]utils.Leaf, added map[string][]utils.Leaf) map[string]string {
+	type candidate struct {
+		from  string
+		to    string
+		score float64
+	}
+
+	candidates := []candidate{}
+	for from, fromLeaves := range removed {
+		for to, toLeaves := range added {
+			if score := similarity(fromLeaves, toLeaves); score >= renameThreshold {
+				candidates = append(candidates, candidate{from, to, score})
+			}
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		if candidates[i].from != candidates[j].from {
+			return candidates[i].from < candidates[j].from
+		}
+		return candidates[i].to < candidates[j].to
+	})
+
+	renames := map[string]string{}
+	matched := map[string]bool{}
+	for _, c := range candidates {
+		if _, ok := renames[c.to]; ok || matched[c.from] {
+			continue
+		}
+		renames[c.to] = c.from
+		matched[c.from] = true
+	}
+	return renames
+}
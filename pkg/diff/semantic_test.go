@@ -0,0 +1,106 @@
+package diff
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+func TestFlatten(t *testing.T) {
+	value := cuecontext.New().CompileString(`
+$metadata: id: "app"
+replicas: *1 | int
+ports: [{port: 80}, {port: 443, protocol: "TCP"}]
+env: [{name: "A", value: "1"}, {name: "B", value: "2"}]
+args: ["--verbose", "--debug"]
+mounts: [{path: "/a"}, {path: "/b"}]
+`)
+
+	expected := map[string]string{
+		`args[0]`:                  `"--verbose"`,
+		`args[1]`:                  `"--debug"`,
+		`env[name="A"].name`:       `"A"`,
+		`env[name="A"].value`:      `"1"`,
+		`env[name="B"].name`:       `"B"`,
+		`env[name="B"].value`:      `"2"`,
+		`mounts[0].path`:           `"/a"`,
+		`mounts[1].path`:           `"/b"`,
+		`ports[port=443].port`:     "443",
+		`ports[port=443].protocol`: `"TCP"`,
+		`ports[port=80].port`:      "80",
+		`replicas`:                 "1",
+	}
+
+	leaves := Flatten(value)
+	if len(leaves) != len(expected) {
+		t.Errorf("Expected %d leaves but found %v", len(expected), leaves)
+	}
+	for _, leaf := range leaves {
+		if value, ok := expected[leaf.Path]; !ok || value != leaf.Value {
+			t.Errorf("Unexpected leaf %s: %s", leaf.Path, leaf.Value)
+		}
+	}
+}
+
+func TestNewReportListInsert(t *testing.T) {
+	a := compileComponents(t, `stack: components: app: env: [{name: "A", value: "1"}, {name: "C", value: "3"}]`)
+	b := compileComponents(t, `stack: components: app: env: [{name: "A", value: "1"}, {name: "B", value: "2"}, {name: "C", value: "3"}]`)
+
+	report := NewReport(a, b, nil)
+	if len(report.Components) != 1 {
+		t.Fatalf("Expected 1 changed component but found %+v", report.Components)
+	}
+	for _, field := range report.Components[0].Fields {
+		if field.Status != Added {
+			t.Errorf("Expected only added fields but found %+v", field)
+		}
+	}
+	if len(report.Components[0].Fields) != 2 {
+		t.Errorf("Expected the name and value of one env entry but found %+v", report.Components[0].Fields)
+	}
+}
+
+func TestNewReportRename(t *testing.T) {
+	a := compileComponents(t, `stack: components: {
+	api: {image: "api:1", port: 8080, env: LOG: "debug", $resources: svc: {$metadata: labels: driver: "compose", image: "api:1"}}
+	db: host: "db"
+}`)
+	b := compileComponents(t, `stack: components: {
+	backend: {image: "api:1", port: 8080, env: LOG: "info", $resources: svc: {$metadata: labels: driver: "compose", image: "api:1"}}
+	queue: host: "queue"
+}`)
+
+	report := NewReport(a, b, nil)
+	if len(report.Components) != 3 {
+		t.Fatalf("Expected 3 changed components but found %+v", report.Components)
+	}
+
+	backend := report.Components[0]
+	if backend.Component != "backend" || backend.Status != Renamed || backend.From != "api" || !backend.Destructive {
+		t.Errorf("Expected api to be renamed to backend but found %+v", backend)
+	}
+	if len(backend.Fields) != 1 || backend.Fields[0].Path != "env.LOG" {
+		t.Errorf("Expected only the env change in the renamed component but found %+v", backend.Fields)
+	}
+	if db := report.Components[1]; db.Component != "db" || db.Status != Removed {
+		t.Errorf("Expected db to be removed but found %+v", db)
+	}
+	if queue := report.Components[2]; queue.Component != "queue" || queue.Status != Added {
+		t.Errorf("Expected queue to be added but found %+v", queue)
+	}
+}
+
+func TestSplitFirst(t *testing.T) {
+	tests := [][3]string{
+		{"app.image", "app", "image"},
+		{`"my.app".image`, `"my.app"`, "image"},
+		{`ports[port=80].port`, "ports", "[port=80].port"},
+		{"app", "app", ""},
+	}
+	for _, test := range tests {
+		first, rest := splitFirst(test[0])
+		if first != test[1] || rest != test[2] {
+			t.Errorf("Expected splitFirst(%s) to be %s, %s but found %s, %s", test[0], test[1], test[2], first, rest)
+		}
+	}
+}
@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"os"
 	"path"
 
 	"cuelang.org/go/cue"
@@ -14,6 +13,7 @@ import (
 
 type ComposeDriver struct {
 	Config stackbuilder.DriverConfig
+	FS     Filesystem
 }
 
 func (d *ComposeDriver) match(resource cue.Value) bool {
@@ -52,17 +52,12 @@ func (d *ComposeDriver) ApplyAll(stack *stack.Stack, stdout bool) error {
 	}
 
 	if stdout {
-		_, err := os.Stdout.Write(data)
+		_, err := stdoutWriter.Write(data)
 		return err
 	}
 
-	if _, err := os.Stat(d.Config.Output.Dir); os.IsNotExist(err) {
-		if err := os.MkdirAll(d.Config.Output.Dir, 0700); err != nil {
-			return err
-		}
-	}
 	filePath := path.Join(d.Config.Output.Dir, d.Config.Output.File)
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	if err := writeFile(d.FS, filePath, data, 0600); err != nil {
 		return err
 	}
 
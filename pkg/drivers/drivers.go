@@ -1,39 +1,70 @@
 package drivers
 
 import (
+	"io"
+	"os"
+	"path/filepath"
+
 	"cuelang.org/go/cue"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stakpak/devx/pkg/redact"
 	"github.com/stakpak/devx/pkg/stack"
 	"github.com/stakpak/devx/pkg/stackbuilder"
 )
 
+// stdoutWriter masks sensitive values when drivers output to stdout
+var stdoutWriter io.Writer = &redact.Writer{W: os.Stdout}
+
+// Filesystem is where drivers write their output, osfs.Default for builds or
+// memfs to compare outputs without touching the project
+type Filesystem interface {
+	billy.Basic
+	billy.Dir
+}
+
 type Driver interface {
 	match(resource cue.Value) bool
 	ApplyAll(stack *stack.Stack, stdout bool) error
 }
 
 // TODO we need to decompose this into DI pattern
-func NewDriversMap(environment string, config map[string]stackbuilder.DriverConfig) map[string]Driver {
+func NewDriversMap(environment string, config map[string]stackbuilder.DriverConfig, fs Filesystem) map[string]Driver {
 	return map[string]Driver{
 		"compose": &ComposeDriver{
 			Config: config["compose"],
+			FS:     fs,
 		},
 		"terraform": &TerraformDriver{
 			Config: config["terraform"],
+			FS:     fs,
 		},
 		"kubernetes": &KubernetesDriver{
 			Config: config["kubernetes"],
+			FS:     fs,
 		},
 		"gitlab": &GitlabDriver{
 			Config: config["gitlab"],
+			FS:     fs,
 		},
 		"github": &GitHubDriver{
 			Config: config["github"],
+			FS:     fs,
 		},
 		"yaml": &YAMLDriver{
 			Config: config["yaml"],
+			FS:     fs,
 		},
 		"json": &JSONDriver{
 			Config: config["json"],
+			FS:     fs,
 		},
 	}
 }
+
+func writeFile(fs Filesystem, filePath string, data []byte, perm os.FileMode) error {
+	if err := fs.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return err
+	}
+	return util.WriteFile(fs, filePath, data, perm)
+}
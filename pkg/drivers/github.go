@@ -2,7 +2,6 @@ package drivers
 
 import (
 	"fmt"
-	"os"
 	"path"
 
 	"cuelang.org/go/cue"
@@ -15,6 +14,7 @@ import (
 
 type GitHubDriver struct {
 	Config stackbuilder.DriverConfig
+	FS     Filesystem
 }
 
 func (d *GitHubDriver) match(resource cue.Value) bool {
@@ -43,22 +43,21 @@ func (d *GitHubDriver) ApplyAll(stack *stack.Stack, stdout bool) error {
 				}
 
 				if stdout {
-					_, err := os.Stdout.Write(data)
+					_, err := stdoutWriter.Write(data)
 					if err != nil {
 						return err
 					}
 					continue
 				}
 
-				if _, err := os.Stat(d.Config.Output.Dir); os.IsNotExist(err) {
-					os.MkdirAll(d.Config.Output.Dir, 0700)
-				}
 				fileName := fmt.Sprintf("%s.yml", resourceIter.Label())
 				if d.Config.Output.File != "" {
 					fileName = d.Config.Output.File
 				}
 				filePath := path.Join(d.Config.Output.Dir, fileName)
-				os.WriteFile(filePath, data, 0700)
+				if err := writeFile(d.FS, filePath, data, 0700); err != nil {
+					return err
+				}
 			}
 		}
 	}
@@ -2,7 +2,6 @@ package drivers
 
 import (
 	"fmt"
-	"os"
 	"path"
 	"path/filepath"
 	"strings"
@@ -17,6 +16,7 @@ import (
 
 type KubernetesDriver struct {
 	Config stackbuilder.DriverConfig
+	FS     Filesystem
 }
 
 func (d *KubernetesDriver) match(resource cue.Value) bool {
@@ -90,27 +90,20 @@ func (d *KubernetesDriver) ApplyAll(stack *stack.Stack, stdout bool) error {
 	for filePath, fileValue := range manifests {
 
 		if stdout {
-			if _, err := os.Stdout.Write([]byte("---\n")); err != nil {
+			if _, err := stdoutWriter.Write([]byte("---\n")); err != nil {
 				return err
 			}
-			if _, err := os.Stdout.Write(fileValue); err != nil {
+			if _, err := stdoutWriter.Write(fileValue); err != nil {
 				return err
 			}
-			_, err := os.Stdout.Write([]byte("\n"))
+			_, err := stdoutWriter.Write([]byte("\n"))
 			return err
 		}
 
-		if _, err := os.Stat(filepath.Dir(filePath)); os.IsNotExist(err) {
-			os.MkdirAll(filepath.Dir(filePath), 0700)
-		}
-		_, err := os.Stat(filePath)
-
-		if !os.IsNotExist(err) {
-			os.Remove(filePath)
+		if err := writeFile(d.FS, filePath, fileValue, 0700); err != nil {
+			return err
 		}
 
-		os.WriteFile(filePath, fileValue, 0700)
-
 		log.Infof("[kubernetes] applied resources to \"%s\"", filePath)
 	}
 
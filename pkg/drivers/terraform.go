@@ -2,9 +2,7 @@ package drivers
 
 import (
 	"encoding/json"
-	"os"
 	"path"
-	"path/filepath"
 
 	"cuelang.org/go/cue"
 	log "github.com/sirupsen/logrus"
@@ -15,6 +13,7 @@ import (
 
 type TerraformDriver struct {
 	Config stackbuilder.DriverConfig
+	FS     Filesystem
 }
 
 func (d *TerraformDriver) match(resource cue.Value) bool {
@@ -83,18 +82,17 @@ func (d *TerraformDriver) ApplyAll(stack *stack.Stack, stdout bool) error {
 		}
 
 		if stdout {
-			_, err := os.Stdout.Write(data)
+			_, err := stdoutWriter.Write(data)
 			if err != nil {
 				return err
 			}
-			_, err = os.Stdout.Write([]byte("\n"))
+			_, err = stdoutWriter.Write([]byte("\n"))
 			return err
 		}
 
-		if _, err := os.Stat(filepath.Dir(filePath)); os.IsNotExist(err) {
-			os.MkdirAll(filepath.Dir(filePath), 0700)
+		if err := writeFile(d.FS, filePath, data, 0700); err != nil {
+			return err
 		}
-		os.WriteFile(filePath, data, 0700)
 
 		log.Infof("[terraform] applied resources to \"%s\"", filePath)
 	}
@@ -1,7 +1,6 @@
 package drivers
 
 import (
-	"os"
 	"path"
 
 	"cuelang.org/go/cue"
@@ -14,6 +13,7 @@ import (
 
 type YAMLDriver struct {
 	Config stackbuilder.DriverConfig
+	FS     Filesystem
 }
 
 func (d *YAMLDriver) match(resource cue.Value) bool {
@@ -51,15 +51,14 @@ func (d *YAMLDriver) ApplyAll(stack *stack.Stack, stdout bool) error {
 	}
 
 	if stdout {
-		_, err := os.Stdout.Write(data)
+		_, err := stdoutWriter.Write(data)
 		return err
 	}
 
-	if _, err := os.Stat(d.Config.Output.Dir); os.IsNotExist(err) {
-		os.MkdirAll(d.Config.Output.Dir, 0700)
-	}
 	filePath := path.Join(d.Config.Output.Dir, d.Config.Output.File)
-	os.WriteFile(filePath, data, 0700)
+	if err := writeFile(d.FS, filePath, data, 0700); err != nil {
+		return err
+	}
 
 	log.Infof("[yaml] applied resources to \"%s\"", filePath)
 
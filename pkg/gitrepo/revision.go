@@ -0,0 +1,91 @@
+package gitrepo
+
+import (
+	"io"
+	"path"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// RevisionFiles reads the files under dir as they are at revision straight
+// from the object store of the repository containing dir, without checking
+// out a worktree. Files are keyed by their slash separated path relative to
+// dir and only files accepted by match are read
+func RevisionFiles(dir string, revision string, match func(string) bool) (map[string][]byte, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := relativePath(w.Filesystem.Root(), dir)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{}
+	if prefix != "." {
+		tree, err = tree.Tree(prefix)
+		if err == object.ErrDirectoryNotFound {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !f.Mode.IsFile() || !match(f.Name) {
+			return nil
+		}
+		reader, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		files[path.Clean(f.Name)] = content
+		return nil
+	})
+	return files, err
+}
+
+func relativePath(root string, dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		absDir = resolved
+	}
+	if resolved, err := filepath.EvalSymlinks(root); err == nil {
+		root = resolved
+	}
+	rel, err := filepath.Rel(root, absDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
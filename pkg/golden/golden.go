@@ -0,0 +1,66 @@
+package golden
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/stakpak/devx/pkg/diff"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// ReadFiles reads all files in fs keyed by their slash separated path
+// relative to the root of fs
+func ReadFiles(fs billy.Filesystem) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	if _, err := fs.Lstat("/"); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := utils.FsWalk(fs, "/", func(p string, content []byte) error {
+		files[strings.TrimPrefix(path.Clean(p), "/")] = content
+		return nil
+	})
+	return files, err
+}
+
+// Check compares outputs against the snapshots in dir
+func Check(outputs map[string][]byte, dir string) ([]diff.FileDiff, error) {
+	snapshots, err := ReadFiles(osfs.New(dir))
+	if err != nil {
+		return nil, err
+	}
+	return diff.Files(snapshots, outputs), nil
+}
+
+// Update rewrites the snapshots in dir to match outputs, snapshots of files
+// that are no longer generated are removed
+func Update(outputs map[string][]byte, dir string) error {
+	diffs, err := Check(outputs, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, fileDiff := range diffs {
+		filePath := filepath.Join(dir, filepath.FromSlash(fileDiff.Path))
+		if fileDiff.Status == diff.Removed {
+			if err := os.Remove(filePath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filePath, outputs[fileDiff.Path], 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
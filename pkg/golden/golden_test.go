@@ -0,0 +1,74 @@
+package golden
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/stakpak/devx/pkg/diff"
+)
+
+func TestGolden(t *testing.T) {
+	fs := memfs.New()
+	if err := util.WriteFile(fs, "build/dev/compose/docker-compose.yml", []byte("services: {}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(fs, "build/dev/terraform/generated.tf.json", []byte("{}\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	outputs, err := ReadFiles(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("Expected 2 output files but found %v", outputs)
+	}
+
+	dir := t.TempDir()
+	stale := filepath.Join(dir, "build", "old.yml")
+	if err := os.MkdirAll(filepath.Dir(stale), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stale, []byte("old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err := Check(outputs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 3 || diffs[0].Status != diff.Added || diffs[2].Status != diff.Removed {
+		t.Fatalf("Expected 2 added and 1 removed files but found %+v", diffs)
+	}
+
+	if err := Update(outputs, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected stale snapshot to be removed")
+	}
+	if diffs, _ := Check(outputs, dir); len(diffs) != 0 {
+		t.Errorf("Expected no differences after update but found %+v", diffs)
+	}
+
+	outputs["build/dev/terraform/generated.tf.json"] = []byte("{\"resource\": {}}\n")
+	diffs, err = Check(outputs, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 || diffs[0].Status != diff.Modified {
+		t.Fatalf("Expected 1 modified file but found %+v", diffs)
+	}
+	expected := `--- a/build/dev/terraform/generated.tf.json
++++ b/build/dev/terraform/generated.tf.json
+@@ -1 +1 @@
+-{}
++{"resource": {}}
+`
+	if diffs[0].Diff != expected {
+		t.Errorf("Expected diff:\n%s\nbut found:\n%s", expected, diffs[0].Diff)
+	}
+}
@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/stack"
+)
+
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+type Node struct {
+	ID        string     `json:"id"`
+	Traits    []string   `json:"traits"`
+	Resources []Resource `json:"resources"`
+}
+type Resource struct {
+	Name   string `json:"name"`
+	Driver string `json:"driver"`
+}
+type Edge struct {
+	From       string            `json:"from"`
+	To         string            `json:"to"`
+	References []stack.Reference `json:"references"`
+}
+
+func New(s *stack.Stack) (*Graph, error) {
+	graph := Graph{
+		Nodes: []Node{},
+		Edges: []Edge{},
+	}
+
+	componentIter, err := s.GetComponents().Fields()
+	if err != nil {
+		return nil, err
+	}
+	for componentIter.Next() {
+		component := componentIter.Value()
+		node := Node{
+			ID:        componentIter.Label(),
+			Traits:    []string{},
+			Resources: []Resource{},
+		}
+
+		traitIter, _ := component.LookupPath(cue.ParsePath("$metadata.traits")).Fields()
+		for traitIter.Next() {
+			node.Traits = append(node.Traits, traitIter.Label())
+		}
+
+		resourceIter, _ := component.LookupPath(cue.ParsePath("$resources")).Fields()
+		for resourceIter.Next() {
+			driver, _ := resourceIter.Value().LookupPath(cue.ParsePath("$metadata.labels.driver")).String()
+			node.Resources = append(node.Resources, Resource{
+				Name:   resourceIter.Label(),
+				Driver: driver,
+			})
+		}
+
+		graph.Nodes = append(graph.Nodes, node)
+	}
+
+	nodes := map[string]bool{}
+	for _, node := range graph.Nodes {
+		nodes[node.ID] = true
+	}
+
+	edges := map[string]*Edge{}
+	addEdge := func(from string, to string) *Edge {
+		key := from + "\x00" + to
+		if _, ok := edges[key]; !ok {
+			edges[key] = &Edge{
+				From:       from,
+				To:         to,
+				References: []stack.Reference{},
+			}
+		}
+		return edges[key]
+	}
+
+	for _, id := range s.GetTasks() {
+		dependencies, err := s.GetDependencies(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, depId := range dependencies {
+			addEdge(id, depId)
+		}
+	}
+
+	for from, refs := range s.GetReferences() {
+		for _, ref := range refs {
+			// references to lets, hidden fields or anything outside the
+			// components are not edges
+			to := strings.SplitN(ref.Source, ".", 2)[0]
+			if to == from || !nodes[to] {
+				continue
+			}
+			edge := addEdge(from, to)
+			edge.References = append(edge.References, ref)
+		}
+	}
+
+	for _, edge := range edges {
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+
+	return &graph, nil
+}
+
+func (g *Graph) Render(format string) (string, error) {
+	switch format {
+	case "dot":
+		return g.dot(), nil
+	case "mermaid":
+		return g.mermaid(), nil
+	case "json":
+		data, err := json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+	return "", fmt.Errorf("unsupported graph format %s, expected dot | mermaid | json", format)
+}
+
+func (g *Graph) dot() string {
+	result := "digraph stack {\n"
+	result += "\trankdir=LR;\n"
+	result += "\tnode [shape=box];\n"
+	for _, node := range g.Nodes {
+		result += fmt.Sprintf("\t%q [label=%q];\n", node.ID, strings.Join(node.labelLines(), "\n"))
+	}
+	for _, edge := range g.Edges {
+		if len(edge.References) > 0 {
+			result += fmt.Sprintf("\t%q -> %q [label=%q];\n", edge.From, edge.To, edge.referencesLabel())
+			continue
+		}
+		result += fmt.Sprintf("\t%q -> %q;\n", edge.From, edge.To)
+	}
+	result += "}\n"
+	return result
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+func (g *Graph) mermaid() string {
+	result := "graph LR\n"
+	for _, node := range g.Nodes {
+		label := strings.ReplaceAll(strings.Join(node.labelLines(), "<br/>"), "\"", "#quot;")
+		result += fmt.Sprintf("\t%s[\"%s\"]\n", mermaidUnsafe.ReplaceAllString(node.ID, "_"), label)
+	}
+	for _, edge := range g.Edges {
+		from := mermaidUnsafe.ReplaceAllString(edge.From, "_")
+		to := mermaidUnsafe.ReplaceAllString(edge.To, "_")
+		if len(edge.References) > 0 {
+			label := strings.ReplaceAll(edge.referencesLabel(), "\"", "#quot;")
+			result += fmt.Sprintf("\t%s -->|\"%s\"| %s\n", from, label, to)
+			continue
+		}
+		result += fmt.Sprintf("\t%s --> %s\n", from, to)
+	}
+	return result
+}
+
+func (n *Node) labelLines() []string {
+	lines := []string{n.ID}
+	if len(n.Traits) > 0 {
+		lines = append(lines, strings.Join(n.Traits, ", "))
+	}
+	for _, resource := range n.Resources {
+		lines = append(lines, fmt.Sprintf("%s: %s", resource.Driver, resource.Name))
+	}
+	return lines
+}
+
+func (e *Edge) referencesLabel() string {
+	labels := []string{}
+	for _, ref := range e.References {
+		parts := strings.SplitN(ref.Source, ".", 2)
+		labels = append(labels, parts[len(parts)-1])
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, "\n")
+}
@@ -0,0 +1,84 @@
+package graph
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/stack"
+)
+
+var graphStack = `
+components: {
+	app: {
+		$metadata: {
+			id: "app"
+			traits: Workload: null
+			dependsOn: ["migrate"]
+		}
+		$resources: app: $metadata: labels: driver: "compose"
+		host: db.host
+		let settings = {region: "eu"}
+		region: settings.region
+	}
+	db: {
+		$metadata: {
+			id: "db"
+			traits: Postgres: null
+		}
+		host: "db"
+	}
+	migrate: {
+		$metadata: id: "migrate"
+	}
+}
+`
+
+func TestRender(t *testing.T) {
+	ctx := cuecontext.New()
+	s, err := stack.NewStack(ctx.CompileString(graphStack), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := New(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dot, err := g.Render("dot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDot := `digraph stack {
+	rankdir=LR;
+	node [shape=box];
+	"app" [label="app\nWorkload\ncompose: app"];
+	"db" [label="db\nPostgres"];
+	"migrate" [label="migrate"];
+	"app" -> "db" [label="host"];
+	"app" -> "migrate";
+}
+`
+	if dot != expectedDot {
+		t.Errorf("Expected dot output:\n%s\nbut found:\n%s", expectedDot, dot)
+	}
+
+	mermaid, err := g.Render("mermaid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMermaid := `graph LR
+	app["app<br/>Workload<br/>compose: app"]
+	db["db<br/>Postgres"]
+	migrate["migrate"]
+	app -->|"host"| db
+	app --> migrate
+`
+	if mermaid != expectedMermaid {
+		t.Errorf("Expected mermaid output:\n%s\nbut found:\n%s", expectedMermaid, mermaid)
+	}
+
+	if _, err := g.Render("svg"); err == nil {
+		t.Error("Expected unsupported format error")
+	}
+}
@@ -0,0 +1,281 @@
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/errors"
+	"github.com/stakpak/devx/pkg/diagnostics"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+)
+
+const (
+	StageRaw         = "raw"
+	StageTransformed = "transformed"
+)
+
+var rulePath = cue.ParsePath("$metadata.rule")
+
+// Rule is a house rule checked against stack components
+//
+//	noLatestTag: {
+//		$metadata: rule: "no-latest-tag"
+//		severity: "warning"
+//		message:  "container images must be pinned"
+//		match: traits: Workload: null
+//		check: containers: [string]: image: !~":latest$"
+//	}
+type Rule struct {
+	Name     string
+	Severity diagnostics.Severity
+	Message  string
+	Stage    string
+	match    cue.Value
+	exclude  cue.Value
+	check    cue.Value
+}
+
+func NewRule(value cue.Value) (*Rule, error) {
+	name, err := value.LookupPath(rulePath).String()
+	if err != nil {
+		return nil, fmt.Errorf("invalid rule name %s: %s", value.Path(), err)
+	}
+
+	rule := Rule{
+		Name:     name,
+		Severity: diagnostics.Error,
+		Message:  fmt.Sprintf("component violates rule %s", name),
+		match:    value.Context().CompileString("{}"),
+		exclude:  value.Context().CompileString("{}"),
+	}
+
+	if severity := value.LookupPath(cue.ParsePath("severity")); severity.Exists() {
+		severityString, err := severity.String()
+		if err != nil {
+			return nil, fmt.Errorf("invalid severity in rule %s: %s", name, err)
+		}
+		switch diagnostics.Severity(severityString) {
+		case diagnostics.Error, diagnostics.Warning, diagnostics.Note:
+			rule.Severity = diagnostics.Severity(severityString)
+		default:
+			return nil, fmt.Errorf("invalid severity %s in rule %s", severityString, name)
+		}
+	}
+
+	if message := value.LookupPath(cue.ParsePath("message")); message.Exists() {
+		if rule.Message, err = message.String(); err != nil {
+			return nil, fmt.Errorf("invalid message in rule %s: %s", name, err)
+		}
+	}
+
+	if stage := value.LookupPath(cue.ParsePath("stage")); stage.Exists() {
+		if rule.Stage, err = stage.String(); err != nil {
+			return nil, fmt.Errorf("invalid stage in rule %s: %s", name, err)
+		}
+		if rule.Stage != StageRaw && rule.Stage != StageTransformed {
+			return nil, fmt.Errorf("invalid stage %s in rule %s, expected %s or %s", rule.Stage, name, StageRaw, StageTransformed)
+		}
+	}
+
+	if match := value.LookupPath(cue.ParsePath("match")); match.Exists() {
+		rule.match = match
+	}
+	if exclude := value.LookupPath(cue.ParsePath("exclude")); exclude.Exists() {
+		rule.exclude = exclude
+	}
+
+	rule.check = value.LookupPath(cue.ParsePath("check"))
+	if !rule.check.Exists() {
+		return nil, fmt.Errorf("rule %s has no check", name)
+	}
+
+	return &rule, nil
+}
+
+// LoadRules finds rules in the top level fields of the project and its
+// dependencies, rules are identified by their name so a rule imported by the
+// project is only loaded once. Definitions are skipped since their checks are
+// closed and would reject any component field not mentioned in the check
+func LoadRules(value cue.Value, dependencies []*build.Instance) ([]*Rule, error) {
+	values := []cue.Value{value}
+	for _, dependency := range dependencies {
+		values = append(values, value.Context().BuildInstance(dependency))
+	}
+
+	rules := []*Rule{}
+	names := map[string]bool{}
+	for _, v := range values {
+		fieldIter, err := v.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for fieldIter.Next() {
+			item := fieldIter.Value()
+			if !item.LookupPath(rulePath).Exists() {
+				continue
+			}
+			rule, err := NewRule(item)
+			if err != nil {
+				return nil, err
+			}
+			if names[rule.Name] {
+				continue
+			}
+			names[rule.Name] = true
+			rules = append(rules, rule)
+		}
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Name < rules[j].Name
+	})
+
+	return rules, nil
+}
+
+// Lint checks the components of a stack, environment is empty for the raw
+// stack and set to the environment name for a transformed stack
+func Lint(rules []*Rule, s *stack.Stack, environment string) diagnostics.Diagnostics {
+	stage := StageRaw
+	if environment != "" {
+		stage = StageTransformed
+	}
+
+	result := diagnostics.Diagnostics{}
+	for _, componentId := range s.GetTasks() {
+		component, err := s.GetComponent(componentId)
+		if err != nil {
+			result = append(result, diagnostics.FromError(err, "stack")...)
+			continue
+		}
+
+		for _, rule := range rules {
+			if rule.Stage != "" && rule.Stage != stage {
+				continue
+			}
+			if !stackbuilder.Match(rule.match, rule.exclude, component) {
+				continue
+			}
+
+			result = append(result, rule.Check(componentId, component, environment)...)
+		}
+	}
+
+	result.Sort()
+	return result
+}
+
+// Check validates a component against the rule check, required fields
+// (field!: type) are reported when missing
+func (r *Rule) Check(componentId string, component cue.Value, environment string) diagnostics.Diagnostics {
+	checked := component.Unify(r.check)
+	err := checked.Validate(cue.Final())
+	if err == nil {
+		return nil
+	}
+
+	result := diagnostics.Diagnostics{}
+	reported := map[string]bool{}
+	componentPath := []string{}
+	for _, selector := range component.Path().Selectors() {
+		componentPath = append(componentPath, selector.String())
+	}
+
+	for _, e := range errors.Errors(err) {
+		path := e.Path()
+		if len(path) >= len(componentPath) && strings.Join(path[:len(componentPath)], ".") == strings.Join(componentPath, ".") {
+			path = path[len(componentPath):]
+		}
+		if isSuppressed(component, path, r.Name) {
+			continue
+		}
+
+		pathString := strings.Join(path, ".")
+		if reported[pathString] {
+			continue
+		}
+		reported[pathString] = true
+
+		format, args := e.Msg()
+		diagnostic := diagnostics.Diagnostic{
+			Severity:    r.Severity,
+			Rule:        r.Name,
+			Path:        pathString,
+			Component:   componentId,
+			Environment: environment,
+			Message:     fmt.Sprintf("%s (%s)", r.Message, fmt.Sprintf(format, args...)),
+		}
+		diagnostic.SetPosition(closestValue(component, path).Pos())
+		result = append(result, diagnostic)
+	}
+
+	return result
+}
+
+// closestValue returns the value at path or its closest existing parent in
+// the component, errors are attributed to the component source instead of
+// the rule source
+func closestValue(component cue.Value, path []string) cue.Value {
+	selectors := pathSelectors(path)
+	for i := len(selectors); i > 0; i-- {
+		v := component.LookupPath(cue.MakePath(selectors[:i]...))
+		if v.Exists() && v.Pos().IsValid() {
+			return v
+		}
+	}
+	return component
+}
+
+// isSuppressed checks for @guku(nolint="rule") on the component or any field
+// along path, @guku(nolint) suppresses all rules
+func isSuppressed(component cue.Value, path []string, rule string) bool {
+	selectors := pathSelectors(path)
+	for i := 0; i <= len(selectors); i++ {
+		v := component
+		if i > 0 {
+			v = component.LookupPath(cue.MakePath(selectors[:i]...))
+			if !v.Exists() {
+				break
+			}
+		}
+
+		attr := v.Attribute("guku")
+		if attr.Err() != nil {
+			continue
+		}
+		if all, _ := attr.Flag(0, "nolint"); all {
+			return true
+		}
+		rules, found, _ := attr.Lookup(0, "nolint")
+		if !found {
+			continue
+		}
+		for _, name := range strings.Split(rules, ",") {
+			if strings.TrimSpace(name) == rule {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func pathSelectors(path []string) []cue.Selector {
+	selectors := []cue.Selector{}
+	for _, fragment := range path {
+		if index, err := strconv.Atoi(fragment); err == nil {
+			selectors = append(selectors, cue.Index(index))
+			continue
+		}
+		selector := cue.ParsePath(fragment).Selectors()
+		if len(selector) != 1 {
+			selector = []cue.Selector{cue.Str(fragment)}
+		}
+		selectors = append(selectors, selector...)
+	}
+	return selectors
+}
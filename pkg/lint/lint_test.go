@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/diagnostics"
+	"github.com/stakpak/devx/pkg/stack"
+)
+
+var lintProject = `
+stack: components: {
+	web: {
+		$metadata: {
+			id: "web"
+			traits: Workload: null
+			labels: owner: "web-team"
+		}
+		image: "nginx:latest"
+	}
+	worker: {
+		$metadata: {
+			id: "worker"
+			traits: Workload: null
+		}
+		image: "worker:1.0"
+	}
+	legacy: {
+		$metadata: {
+			id: "legacy"
+			traits: Workload: null
+		}
+		image: "legacy:latest" @guku(nolint="no-latest-tag")
+	} @guku(nolint="owner-label")
+	db: {
+		$metadata: {
+			id: "db"
+			traits: Postgres: null
+		}
+	}
+}
+
+noLatestTag: {
+	$metadata: rule: "no-latest-tag"
+	severity: "warning"
+	message:  "container images must be pinned"
+	match: traits: Workload: null
+	check: image: !~":latest$"
+}
+
+ownerLabel: {
+	$metadata: rule: "owner-label"
+	message: "components must have an owner"
+	check: $metadata: labels: owner!: string
+}
+
+transformedOnly: {
+	$metadata: rule: "has-resources"
+	stage: "transformed"
+	check: $resources!: _
+}
+`
+
+func TestLint(t *testing.T) {
+	value := cuecontext.New().CompileString(lintProject, cue.Filename("stack.cue"))
+
+	rules, err := LoadRules(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("Expected 3 rules but found %d", len(rules))
+	}
+
+	s, err := stack.NewStack(value.LookupPath(cue.ParsePath("stack")), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Lint(rules, s, "")
+	found := map[string]diagnostics.Diagnostic{}
+	for _, diagnostic := range result {
+		found[diagnostic.Rule+"/"+diagnostic.Component] = diagnostic
+	}
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 diagnostics but found %d:\n%s", len(result), result)
+	}
+
+	latest, ok := found["no-latest-tag/web"]
+	if !ok {
+		t.Fatalf("Expected no-latest-tag violation for web but found:\n%s", result)
+	}
+	if latest.Severity != diagnostics.Warning || latest.Path != "image" || latest.File != "stack.cue" || latest.Line != 9 {
+		t.Errorf("Unexpected diagnostic %s", latest)
+	}
+	for _, key := range []string{"owner-label/worker", "owner-label/db"} {
+		if diagnostic, ok := found[key]; !ok || diagnostic.Severity != diagnostics.Error {
+			t.Errorf("Expected %s violation but found:\n%s", key, result)
+		}
+	}
+}
+
+func TestLintTransformed(t *testing.T) {
+	value := cuecontext.New().CompileString(lintProject)
+
+	rules, err := LoadRules(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := stack.NewStack(value.LookupPath(cue.ParsePath("stack")), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Lint(rules, s, "dev")
+	resources := 0
+	for _, diagnostic := range result {
+		if diagnostic.Environment != "dev" {
+			t.Errorf("Expected diagnostic to be attributed to dev but found %s", diagnostic)
+		}
+		if diagnostic.Rule == "has-resources" {
+			resources++
+		}
+	}
+	if resources != 4 {
+		t.Errorf("Expected has-resources violations for all 4 components but found:\n%s", result)
+	}
+}
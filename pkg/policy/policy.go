@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/build"
 	"cuelang.org/go/encoding/gocode/gocodec"
 	log "github.com/sirupsen/logrus"
 	"github.com/stakpak/devx/pkg/auth"
@@ -33,7 +34,7 @@ func Publish(configDir string, server auth.ServerConfig) error {
 		return err
 	}
 
-	value, _, _ := utils.LoadProject(configDir, &overlays)
+	value, _, _ := utils.LoadProject(configDir, &overlays, "")
 	codec := gocodec.New((*cue.Runtime)(value.Context()), nil)
 
 	fieldIter, err := value.Fields()
@@ -74,3 +75,104 @@ func Publish(configDir string, server auth.ServerConfig) error {
 
 	return nil
 }
+
+// Policy is a global policy loaded from the project or its dependencies to be
+// enforced locally during builds
+type Policy struct {
+	Name         string
+	Environments []string
+	IsEnforced   bool
+	IsDisabled   bool
+	Pipeline     []cue.Value
+}
+
+// AppliesTo reports whether the policy is enforced in environment, policies
+// without environments apply to all environments
+func (p *Policy) AppliesTo(environment string) bool {
+	if !p.IsEnforced || p.IsDisabled {
+		return false
+	}
+	if len(p.Environments) == 0 {
+		return true
+	}
+	for _, env := range p.Environments {
+		if env == environment {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadPolicies finds policies in the top level fields of the project and its
+// dependencies, a policy imported by the project is only loaded once
+func LoadPolicies(value cue.Value, dependencies []*build.Instance) ([]*Policy, error) {
+	values := []cue.Value{value}
+	for _, dependency := range dependencies {
+		values = append(values, value.Context().BuildInstance(dependency))
+	}
+
+	policies := []*Policy{}
+	names := map[string]bool{}
+	for _, v := range values {
+		fieldIter, err := v.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for fieldIter.Next() {
+			item := fieldIter.Value()
+			if !item.LookupPath(policyNamePath).Exists() {
+				continue
+			}
+			policy, err := newPolicy(item)
+			if err != nil {
+				return nil, err
+			}
+			if names[policy.Name] {
+				continue
+			}
+			names[policy.Name] = true
+			policies = append(policies, policy)
+		}
+	}
+
+	return policies, nil
+}
+
+func newPolicy(value cue.Value) (*Policy, error) {
+	name, err := value.LookupPath(policyNamePath).String()
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy name %s", value.Path())
+	}
+
+	policy := Policy{
+		Name:         name,
+		Environments: []string{},
+		Pipeline:     []cue.Value{},
+	}
+
+	if environments := value.LookupPath(cue.ParsePath("environments")); environments.Exists() {
+		if err := environments.Decode(&policy.Environments); err != nil {
+			return nil, fmt.Errorf("invalid environments in policy %s: %s", name, err)
+		}
+	}
+	if enforced := value.LookupPath(cue.ParsePath("enforced")); enforced.Exists() {
+		if policy.IsEnforced, err = enforced.Bool(); err != nil {
+			return nil, fmt.Errorf("invalid enforced in policy %s: %s", name, err)
+		}
+	}
+	if disabled := value.LookupPath(cue.ParsePath("disabled")); disabled.Exists() {
+		if policy.IsDisabled, err = disabled.Bool(); err != nil {
+			return nil, fmt.Errorf("invalid disabled in policy %s: %s", name, err)
+		}
+	}
+
+	pipelineIter, err := value.LookupPath(cue.ParsePath("pipeline")).List()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pipeline in policy %s: %s", name, err)
+	}
+	for pipelineIter.Next() {
+		policy.Pipeline = append(policy.Pipeline, pipelineIter.Value())
+	}
+
+	return &policy, nil
+}
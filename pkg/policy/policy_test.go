@@ -0,0 +1,66 @@
+package policy
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+)
+
+var policyProject = `
+noPrivileged: {
+	$metadata: policy: "no-privileged"
+	environments: ["prod"]
+	enforced: true
+	pipeline: [{
+		$metadata: traits: Workload: null
+		privileged: false
+	}]
+}
+ownerLabel: {
+	$metadata: policy: "owner-label"
+	enforced: true
+	pipeline: [{$metadata: labels: owner: string}]
+}
+advisory: {
+	$metadata: policy: "advisory"
+	enforced: false
+	pipeline: []
+}
+disabled: {
+	$metadata: policy: "disabled"
+	enforced: true
+	disabled: true
+	pipeline: []
+}
+`
+
+func TestLoadPolicies(t *testing.T) {
+	value := cuecontext.New().CompileString(policyProject)
+
+	policies, err := LoadPolicies(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 4 {
+		t.Fatalf("Expected 4 policies but found %d", len(policies))
+	}
+
+	applied := map[string][]string{}
+	for _, environment := range []string{"dev", "prod"} {
+		for _, policy := range policies {
+			if policy.AppliesTo(environment) {
+				applied[environment] = append(applied[environment], policy.Name)
+			}
+		}
+	}
+
+	if len(applied["dev"]) != 1 || applied["dev"][0] != "owner-label" {
+		t.Errorf("Expected only owner-label in dev but found %v", applied["dev"])
+	}
+	if len(applied["prod"]) != 2 {
+		t.Errorf("Expected no-privileged and owner-label in prod but found %v", applied["prod"])
+	}
+	if len(policies[0].Pipeline) != 1 {
+		t.Errorf("Expected 1 transformer in no-privileged pipeline but found %d", len(policies[0].Pipeline))
+	}
+}
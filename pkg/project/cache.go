@@ -0,0 +1,115 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+const cacheDirEnv = "DEVX_CACHE_DIR"
+
+// ModuleCacheDir is the directory git dependencies are cached in, it can be
+// overridden with DEVX_CACHE_DIR
+func ModuleCacheDir() (string, error) {
+	if dir := os.Getenv(cacheDirEnv); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".devx", "cache", "mod"), nil
+}
+
+// moduleCache stores the worktree of each git dependency commit once in
+// <dir>/<repo>@<commit>, cached trees are never modified
+type moduleCache struct {
+	dir string
+}
+
+func newModuleCache() (*moduleCache, error) {
+	dir, err := ModuleCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &moduleCache{dir: dir}, nil
+}
+
+func (c *moduleCache) path(name string, hash string) string {
+	return filepath.Join(c.dir, filepath.FromSlash(name)+"@"+hash)
+}
+
+// get returns the cached tree and commit for hash, hash can also be an alias
+// such as an annotated tag pointing to the commit
+func (c *moduleCache) get(name string, hash string) (billy.Filesystem, plumbing.Hash, bool) {
+	if !plumbing.IsHash(hash) {
+		return nil, plumbing.ZeroHash, false
+	}
+
+	dir, err := filepath.EvalSymlinks(c.path(name, hash))
+	if err != nil {
+		return nil, plumbing.ZeroHash, false
+	}
+	commit := dir[strings.LastIndex(dir, "@")+1:]
+	if !plumbing.IsHash(commit) {
+		return nil, plumbing.ZeroHash, false
+	}
+	return osfs.New(dir), plumbing.NewHash(commit), true
+}
+
+// put copies the worktree of a commit into the cache, the tree is written to
+// a temporary directory first so that interrupted or concurrent updates never
+// leave a partial tree behind
+func (c *moduleCache) put(name string, commit plumbing.Hash, fs billy.Filesystem) (billy.Filesystem, error) {
+	target := c.path(name, commit.String())
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(target), ".tmp-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = utils.FsWalk(fs, "", func(file string, content []byte) error {
+		writePath := filepath.Join(tmpDir, filepath.FromSlash(path.Clean(file)))
+		if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(writePath, content, 0644)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmpDir, target); err != nil {
+		if _, statErr := os.Stat(target); statErr != nil {
+			return nil, fmt.Errorf("failed to cache %s@%s: %s", name, commit, err)
+		}
+		// another update cached the same commit first
+	}
+	return osfs.New(target), nil
+}
+
+// alias links a hash that resolves to commit, like an annotated tag, to the
+// cached tree of commit
+func (c *moduleCache) alias(name string, hash string, commit plumbing.Hash) error {
+	if !plumbing.IsHash(hash) || hash == commit.String() {
+		return nil
+	}
+	link := c.path(name, hash)
+	if _, err := os.Lstat(link); err == nil {
+		return nil
+	}
+	return os.Symlink(filepath.Base(c.path(name, commit.String())), link)
+}
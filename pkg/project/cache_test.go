@@ -0,0 +1,77 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestModuleCache(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(cacheDirEnv, dir)
+
+	cache, err := newModuleCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := "github.com/example/lib"
+	commit := plumbing.NewHash("3f1c0de3f1c0de3f1c0de3f1c0de3f1c0de3f1c0")
+	tagHash := "aaaabbbbccccddddeeeeffff0000111122223333"
+	if _, _, ok := cache.get(name, commit.String()); ok {
+		t.Fatal("Expected an empty cache")
+	}
+
+	worktree := memfs.New()
+	if err := util.WriteFile(worktree, "cue.mod/module.cue", []byte("module: \"example.com/lib\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := util.WriteFile(worktree, "lib/lib.cue", []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cache.put(name, commit, worktree); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.alias(name, tagHash, commit); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, hash := range []string{commit.String(), tagHash} {
+		fs, cachedCommit, ok := cache.get(name, hash)
+		if !ok {
+			t.Fatalf("Expected %s to be cached", hash)
+		}
+		if cachedCommit != commit {
+			t.Errorf("Expected %s to resolve to commit %s but found %s", hash, commit, cachedCommit)
+		}
+		content, err := util.ReadFile(fs, "lib/lib.cue")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != "package lib\n" {
+			t.Errorf("Unexpected cached content %q", content)
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, "github.com", "example"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected the commit and its alias without temporary directories but found %d entries", len(entries))
+	}
+
+	// putting the same commit again keeps the existing tree
+	if _, err := cache.put(name, commit, worktree); err != nil {
+		t.Errorf("Expected caching an existing commit to succeed but found %s", err)
+	}
+
+	if _, _, ok := cache.get(name, "../../etc"); ok {
+		t.Errorf("Expected invalid hashes to be rejected")
+	}
+}
@@ -0,0 +1,259 @@
+package project
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/mod/semver"
+)
+
+// Constraint is a range of semantic versions such as ^1.2, ~1.2.3 or
+// >=1.4 <2. Space separated comparators must all match and ranges can be
+// combined with ||
+type Constraint struct {
+	raw        string
+	ranges     [][]comparator
+	prerelease bool
+}
+
+type comparator struct {
+	op      string
+	version string
+}
+
+// IsConstraint reports whether a dependency version is a range rather than
+// an exact revision like a tag, branch or commit
+func IsConstraint(v string) bool {
+	v = strings.TrimSpace(v)
+	return strings.ContainsAny(v, "^~<>=*|") || strings.Contains(v, " ")
+}
+
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{raw: strings.TrimSpace(s)}
+	for _, part := range strings.Split(c.raw, "||") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid version constraint %q: empty range", s)
+		}
+
+		comparators := []comparator{}
+		for i := 0; i < len(fields); i++ {
+			field := fields[i]
+			// allow a space between the operator and the version, e.g. ">= 1.4"
+			if strings.Trim(field, "^~<>=") == "" && i+1 < len(fields) {
+				i++
+				field += fields[i]
+			}
+			parsed, err := parseComparator(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid version constraint %q: %s", s, err)
+			}
+			comparators = append(comparators, parsed...)
+		}
+		c.ranges = append(c.ranges, comparators)
+	}
+
+	// -0 only bounds ranges and does not opt into prereleases
+	for _, comparators := range c.ranges {
+		for _, comp := range comparators {
+			if prerelease := semver.Prerelease(comp.version); prerelease != "" && prerelease != "-0" {
+				c.prerelease = true
+			}
+		}
+	}
+	return c, nil
+}
+
+// Check reports whether version satisfies the constraint, prereleases only
+// match constraints that mention a prerelease
+func (c *Constraint) Check(version string) bool {
+	version, ok := canonicalVersion(version)
+	if !ok {
+		return false
+	}
+	if semver.Prerelease(version) != "" && !c.prerelease {
+		return false
+	}
+
+	for _, comparators := range c.ranges {
+		matched := true
+		for _, comp := range comparators {
+			if !comp.check(version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Constraint) String() string {
+	return c.raw
+}
+
+func (comp comparator) check(version string) bool {
+	cmp := semver.Compare(version, comp.version)
+	switch comp.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp == 0
+}
+
+// parseComparator expands a single operator and a possibly partial version
+// into plain comparators
+func parseComparator(s string) ([]comparator, error) {
+	if s == "*" || s == "x" || s == "X" {
+		return []comparator{{">=", "v0.0.0-0"}}, nil
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(s, prefix) {
+			op = prefix
+			break
+		}
+	}
+	p, err := parsePartial(strings.TrimPrefix(s, op))
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "^":
+		switch {
+		case p.major > 0 || p.parts == 1:
+			return p.between(p.nextMajor()), nil
+		case p.minor > 0 || p.parts == 2:
+			return p.between(p.nextMinor()), nil
+		default:
+			return p.between(p.nextPatch()), nil
+		}
+	case "~":
+		if p.parts == 1 {
+			return p.between(p.nextMajor()), nil
+		}
+		return p.between(p.nextMinor()), nil
+	case ">=":
+		return []comparator{{">=", p.version()}}, nil
+	case "<":
+		return []comparator{{"<", p.version()}}, nil
+	case ">":
+		if p.parts < 3 {
+			return []comparator{{">=", p.upper()}}, nil
+		}
+		return []comparator{{">", p.version()}}, nil
+	case "<=":
+		if p.parts < 3 {
+			return []comparator{{"<", p.upper()}}, nil
+		}
+		return []comparator{{"<=", p.version()}}, nil
+	}
+
+	// = or no operator, a partial version matches the whole range it covers
+	if p.parts < 3 {
+		return p.between(p.upper()), nil
+	}
+	return []comparator{{"=", p.version()}}, nil
+}
+
+type partialVersion struct {
+	major      int
+	minor      int
+	patch      int
+	prerelease string
+	parts      int
+}
+
+// parsePartial parses versions like v1, 1.2 or 1.2.3-rc.1, x and * are
+// accepted in place of the missing parts
+func parsePartial(s string) (partialVersion, error) {
+	p := partialVersion{}
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		if s[i] == '-' {
+			p.prerelease = strings.SplitN(s[i:], "+", 2)[0]
+		}
+		s = s[:i]
+	}
+
+	numbers := []*int{&p.major, &p.minor, &p.patch}
+	parts := strings.Split(s, ".")
+	if len(parts) > len(numbers) {
+		return p, fmt.Errorf("%q is not a version", s)
+	}
+	for i, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("%q is not a version", s)
+		}
+		*numbers[i] = n
+		p.parts++
+	}
+	if p.parts == 0 {
+		return p, fmt.Errorf("%q is not a version", s)
+	}
+	if p.prerelease != "" && p.parts < 3 {
+		return p, fmt.Errorf("%q prereleases need a full version", s)
+	}
+	return p, nil
+}
+
+func (p partialVersion) version() string {
+	return fmt.Sprintf("v%d.%d.%d%s", p.major, p.minor, p.patch, p.prerelease)
+}
+
+// between matches versions from p up to upper, upper and its prereleases
+// are excluded
+func (p partialVersion) between(upper string) []comparator {
+	return []comparator{{">=", p.version()}, {"<", upper + "-0"}}
+}
+
+// upper is the first version after the range covered by a partial version
+func (p partialVersion) upper() string {
+	switch p.parts {
+	case 1:
+		return p.nextMajor()
+	case 2:
+		return p.nextMinor()
+	}
+	return p.nextPatch()
+}
+
+func (p partialVersion) nextMajor() string {
+	return fmt.Sprintf("v%d.0.0", p.major+1)
+}
+
+func (p partialVersion) nextMinor() string {
+	return fmt.Sprintf("v%d.%d.0", p.major, p.minor+1)
+}
+
+func (p partialVersion) nextPatch() string {
+	return fmt.Sprintf("v%d.%d.%d", p.major, p.minor, p.patch+1)
+}
+
+// canonicalVersion normalizes tags with or without a v prefix, ok is false
+// for tags that are not semantic versions
+func canonicalVersion(tag string) (string, bool) {
+	version := tag
+	if !strings.HasPrefix(version, "v") {
+		version = "v" + version
+	}
+	if !semver.IsValid(version) {
+		return "", false
+	}
+	return semver.Canonical(version), true
+}
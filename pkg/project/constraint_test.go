@@ -0,0 +1,169 @@
+package project
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+func TestConstraintCheck(t *testing.T) {
+	tests := []struct {
+		constraint string
+		matches    []string
+		rejects    []string
+	}{
+		{"^1.2", []string{"v1.2.0", "1.9.3", "v1.2.0+build"}, []string{"v1.1.9", "v2.0.0", "v2.0.0-rc.1", "v1.3.0-beta"}},
+		{"^0.2.1", []string{"v0.2.1", "v0.2.9"}, []string{"v0.3.0", "v0.2.0"}},
+		{"~1.2", []string{"v1.2.0", "v1.2.7"}, []string{"v1.3.0", "v1.1.0"}},
+		{">=1.4 <2", []string{"v1.4.0", "v1.99.0"}, []string{"v1.3.9", "v2.0.0"}},
+		{">= 1.4", []string{"v1.4.0", "v3.0.0"}, []string{"v1.3.0"}},
+		{">1.2", []string{"v1.3.0"}, []string{"v1.2.5"}},
+		{"<=1.2", []string{"v1.2.5"}, []string{"v1.3.0"}},
+		{"1.x", []string{"v1.0.0", "v1.5.0"}, []string{"v2.0.0"}},
+		{"^1 || ^3", []string{"v1.1.0", "v3.0.0"}, []string{"v2.0.0"}},
+		{"=v1.2.3", []string{"v1.2.3"}, []string{"v1.2.4"}},
+		{">=1.0.0-rc.1", []string{"v1.0.0-rc.2", "v1.0.0"}, []string{"v0.9.0", "main"}},
+		{"*", []string{"v0.0.1", "v9.0.0"}, []string{"v1.0.0-alpha"}},
+	}
+
+	for _, tt := range tests {
+		constraint, err := ParseConstraint(tt.constraint)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %s", tt.constraint, err)
+		}
+		for _, version := range tt.matches {
+			if !constraint.Check(version) {
+				t.Errorf("Expected %s to match %s", version, tt.constraint)
+			}
+		}
+		for _, version := range tt.rejects {
+			if constraint.Check(version) {
+				t.Errorf("Expected %s not to match %s", version, tt.constraint)
+			}
+		}
+	}
+}
+
+func TestParseConstraintInvalid(t *testing.T) {
+	for _, constraint := range []string{"^", ">=1.4 ||", "^1.2.3.4", ">=abc", "^1.2-rc.1"} {
+		if _, err := ParseConstraint(constraint); err == nil {
+			t.Errorf("Expected %q to be invalid", constraint)
+		}
+	}
+}
+
+func TestIsConstraint(t *testing.T) {
+	for v, expected := range map[string]bool{
+		"^1.2":      true,
+		">=1.4 <2":  true,
+		"*":         true,
+		"v1.2.3":    false,
+		"main":      false,
+		"3f1c0deab": false,
+	} {
+		if IsConstraint(v) != expected {
+			t.Errorf("Expected IsConstraint(%q) to be %v", v, expected)
+		}
+	}
+}
+
+func version(v string) *string {
+	return &v
+}
+
+func fakeResolver(tags map[string][]string, packages map[string]map[string]catalog.ModuleDependency) *resolver {
+	return &resolver{
+		versions: func(name string) ([]string, error) {
+			return tags[name], nil
+		},
+		dependencies: func(name string, v *string) (map[string]catalog.ModuleDependency, error) {
+			return packages[name+"@"+versionString(v)], nil
+		},
+	}
+}
+
+func TestResolveMinimalVersion(t *testing.T) {
+	r := fakeResolver(
+		map[string][]string{
+			"stakpak://a":            {"v1.0.0", "v1.1.0", "v1.2.0", "v2.0.0"},
+			"stakpak://b":            {"v1.0.0", "v1.3.0", "v1.4.0", "v1.5.0"},
+			"github.com/example/lib": {"0.1.0", "v0.2.0", "main"},
+		},
+		map[string]map[string]catalog.ModuleDependency{
+			"stakpak://a@v1.1.0": {
+				"stakpak://b": {V: version(">=1.4 <2")},
+			},
+		},
+	)
+
+	deps, err := r.resolve(map[string]catalog.ModuleDependency{
+		"stakpak://a":            {V: version("^1.1")},
+		"stakpak://b":            {V: version("^1.2")},
+		"github.com/example/lib": {V: version("~0.1")},
+		"github.com/example/cat": {V: nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"stakpak://a":            "v1.1.0",
+		"stakpak://b":            "v1.4.0",
+		"github.com/example/lib": "0.1.0",
+		"github.com/example/cat": "<untagged>",
+	}
+	if len(deps) != len(expected) {
+		t.Fatalf("Expected %d dependencies but found %v", len(expected), deps)
+	}
+	for name, v := range expected {
+		if versionString(deps[name].V) != v {
+			t.Errorf("Expected %s to resolve to %s but found %s", name, v, versionString(deps[name].V))
+		}
+	}
+}
+
+func TestResolveCollision(t *testing.T) {
+	r := fakeResolver(
+		map[string][]string{
+			"stakpak://c": {"v1.0.0", "v2.0.0"},
+		},
+		map[string]map[string]catalog.ModuleDependency{
+			"stakpak://a@v1.0.0": {"stakpak://b": {V: version("v1.0.0")}},
+			"stakpak://b@v1.0.0": {"stakpak://c": {V: version("^1")}},
+			"stakpak://d@v1.0.0": {"stakpak://c": {V: version("^2")}},
+		},
+	)
+
+	_, err := r.resolve(map[string]catalog.ModuleDependency{
+		"stakpak://a": {V: version("v1.0.0")},
+		"stakpak://d": {V: version("v1.0.0")},
+	})
+	if err == nil {
+		t.Fatal("Expected a dependency collision")
+	}
+	for _, expected := range []string{
+		"dependency collision for stakpak://c",
+		"module.cue -> stakpak://a@v1.0.0 -> stakpak://b@v1.0.0 requires ^1",
+		"module.cue -> stakpak://d@v1.0.0 requires ^2",
+	} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error to contain %q but found %s", expected, err)
+		}
+	}
+
+	_, err = r.resolve(map[string]catalog.ModuleDependency{
+		"stakpak://c": {V: version("^3")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "no version of stakpak://c matches ^3") {
+		t.Errorf("Expected an unsatisfiable constraint error but found %v", err)
+	}
+
+	_, err = r.resolve(map[string]catalog.ModuleDependency{
+		"stakpak://b": {V: version("v1.0.0")},
+		"stakpak://c": {V: version("main")},
+	})
+	if err == nil || !strings.Contains(err.Error(), "main is a branch or commit") {
+		t.Errorf("Expected a branch collision error but found %v", err)
+	}
+}
@@ -0,0 +1,344 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/olekukonko/tablewriter"
+	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/catalog"
+	"golang.org/x/mod/semver"
+)
+
+type DependencyNode struct {
+	Module       string           `json:"module"`
+	Requested    string           `json:"requested"`
+	Version      string           `json:"version"`
+	Commit       string           `json:"commit,omitempty"`
+	Replace      string           `json:"replace,omitempty"`
+	Dependencies []DependencyNode `json:"dependencies,omitempty"`
+}
+
+type OutdatedDependency struct {
+	Module   string `json:"module"`
+	Current  string `json:"current"`
+	Latest   string `json:"latest"`
+	Indirect bool   `json:"indirect"`
+}
+
+type DependencyReason struct {
+	Module  string           `json:"module"`
+	Version string           `json:"version"`
+	Paths   []DependencyPath `json:"paths"`
+}
+
+type DependencyPath struct {
+	Path     []string `json:"path"`
+	Requires string   `json:"requires"`
+}
+
+// dependencyGraph is the resolved dependency graph of a project, the locked
+// versions are used if the lock is up to date with module.cue
+type dependencyGraph struct {
+	resolver *resolver
+	deps     map[string]catalog.ModuleDependency
+	replace  map[string]catalog.ModuleReplacement
+	selected map[string]catalog.ModuleDependency
+	lock     *Lock
+}
+
+func loadDependencyGraph(configDir string, server auth.ServerConfig) (*dependencyGraph, error) {
+	_, deps, replace, err := readModuleDependencies(configDir)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := ReadLock(configDir)
+	if err != nil {
+		return nil, err
+	}
+	return newDependencyGraph(newResolver(server, replace), deps, replace, lock)
+}
+
+func newDependencyGraph(r *resolver, deps map[string]catalog.ModuleDependency, replace map[string]catalog.ModuleReplacement, lock *Lock) (*dependencyGraph, error) {
+	g := &dependencyGraph{
+		resolver: r,
+		deps:     deps,
+		replace:  replace,
+	}
+
+	if lock != nil && lock.Satisfies(deps, replace) == nil {
+		g.lock = lock
+		g.selected = map[string]catalog.ModuleDependency{}
+		for name, locked := range lock.Dependencies {
+			g.selected[name] = catalog.ModuleDependency{V: locked.resolved()}
+		}
+		return g, nil
+	}
+
+	selected, err := r.resolve(deps)
+	if err != nil {
+		return nil, err
+	}
+	g.selected = selected
+	return g, nil
+}
+
+// Tree lists the direct dependencies with their nested dependencies
+func (g *dependencyGraph) Tree() ([]DependencyNode, error) {
+	nodes := []DependencyNode{}
+	for _, name := range sortedNames(g.deps) {
+		node, err := g.node(name, g.deps[name].V, []string{})
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
+}
+
+func (g *dependencyGraph) node(name string, requested *string, parents []string) (DependencyNode, error) {
+	selected := g.selected[name]
+	node := DependencyNode{
+		Module:    name,
+		Requested: versionString(requested),
+		Version:   versionString(selected.V),
+	}
+	if replacement, ok := g.replace[name]; ok {
+		node.Replace = replacementString(&replacement)
+	}
+	if g.lock != nil {
+		node.Commit = g.lock.Dependencies[name].Commit
+	}
+
+	if !strings.HasPrefix(name, stakpakPrefix) || node.Replace != "" || contains(parents, name) {
+		return node, nil
+	}
+	if len(parents) >= maxResolutionDepth {
+		return node, fmt.Errorf("exceeded allowed dependency resolution depth")
+	}
+
+	nested, err := g.resolver.cachedDependencies(name, selected.V)
+	if err != nil {
+		return node, err
+	}
+	for _, nestedName := range sortedNames(nested) {
+		child, err := g.node(nestedName, nested[nestedName].V, append(append([]string{}, parents...), name))
+		if err != nil {
+			return node, err
+		}
+		node.Dependencies = append(node.Dependencies, child)
+	}
+	return node, nil
+}
+
+// Outdated lists the dependencies with a newer release than the selected
+// version, replaced and unpinned dependencies are skipped
+func (g *dependencyGraph) Outdated() ([]OutdatedDependency, error) {
+	result := []OutdatedDependency{}
+	for _, name := range sortedNames(g.selected) {
+		if _, ok := g.replace[name]; ok {
+			continue
+		}
+
+		tags, err := g.resolver.cachedVersions(name)
+		if err != nil {
+			return nil, err
+		}
+		latest := ""
+		for _, tag := range tags {
+			if version, _ := canonicalVersion(tag); semver.Prerelease(version) == "" {
+				latest = tag
+			}
+		}
+		if latest == "" {
+			continue
+		}
+
+		// untagged, branch and commit pins have no version to upgrade from
+		current := g.selected[name].V
+		if current == nil {
+			continue
+		}
+		version, ok := canonicalVersion(*current)
+		if !ok {
+			continue
+		}
+		latestVersion, _ := canonicalVersion(latest)
+		if semver.Compare(version, latestVersion) >= 0 {
+			continue
+		}
+
+		_, isDirect := g.deps[name]
+		result = append(result, OutdatedDependency{
+			Module:   name,
+			Current:  versionString(current),
+			Latest:   latest,
+			Indirect: !isDirect,
+		})
+	}
+	return result, nil
+}
+
+// Why lists the packages that require module, each by its shortest
+// dependency path from module.cue
+func (g *dependencyGraph) Why(module string) (DependencyReason, error) {
+	requirements, err := g.resolver.requirements(g.deps, g.selected)
+	if err != nil {
+		return DependencyReason{}, err
+	}
+	reqs, ok := requirements[module]
+	if !ok {
+		return DependencyReason{}, fmt.Errorf("%s is not a dependency of this project", module)
+	}
+
+	reason := DependencyReason{
+		Module:  module,
+		Version: versionString(g.selected[module].V),
+		Paths:   []DependencyPath{},
+	}
+	for _, req := range reqs {
+		reason.Paths = append(reason.Paths, DependencyPath{
+			Path:     append(append([]string{"module.cue"}, req.Path...), module),
+			Requires: versionString(req.V),
+		})
+	}
+	return reason, nil
+}
+
+// DepsTree prints the resolved dependency tree as text or json
+func DepsTree(configDir string, server auth.ServerConfig, output string) error {
+	if err := checkDepsOutput(output); err != nil {
+		return err
+	}
+	g, err := loadDependencyGraph(configDir, server)
+	if err != nil {
+		return err
+	}
+	nodes, err := g.Tree()
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, nodes)
+	}
+	fmt.Println("module.cue")
+	printTree(os.Stdout, nodes, "")
+	return nil
+}
+
+// DepsOutdated prints dependencies with newer git tags or Hub versions
+func DepsOutdated(configDir string, server auth.ServerConfig, output string) error {
+	if err := checkDepsOutput(output); err != nil {
+		return err
+	}
+	g, err := loadDependencyGraph(configDir, server)
+	if err != nil {
+		return err
+	}
+	outdated, err := g.Outdated()
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, outdated)
+	}
+	if len(outdated) == 0 {
+		fmt.Println("All dependencies are up to date")
+		return nil
+	}
+
+	tableData := [][]string{}
+	for _, dep := range outdated {
+		indirect := ""
+		if dep.Indirect {
+			indirect = "indirect"
+		}
+		tableData = append(tableData, []string{dep.Module, dep.Current, dep.Latest, indirect})
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Module", "Current", "Latest", ""})
+	table.SetAutoFormatHeaders(false)
+	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetRowSeparator("")
+	table.SetHeaderLine(false)
+	table.SetBorder(false)
+	table.SetTablePadding("\t")
+	table.SetNoWhiteSpace(true)
+	table.AppendBulk(tableData)
+	table.Render()
+	return nil
+}
+
+// DepsWhy prints the dependency paths that pulled in a module
+func DepsWhy(configDir string, server auth.ServerConfig, module string, output string) error {
+	if err := checkDepsOutput(output); err != nil {
+		return err
+	}
+	g, err := loadDependencyGraph(configDir, server)
+	if err != nil {
+		return err
+	}
+	reason, err := g.Why(module)
+	if err != nil {
+		return err
+	}
+
+	if output == "json" {
+		return printJSON(os.Stdout, reason)
+	}
+	fmt.Printf("%s@%s\n", reason.Module, reason.Version)
+	for _, p := range reason.Paths {
+		fmt.Printf("\t%s requires %s\n", strings.Join(p.Path, " -> "), p.Requires)
+	}
+	return nil
+}
+
+func printTree(w io.Writer, nodes []DependencyNode, indent string) {
+	for i, node := range nodes {
+		branch, childIndent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, childIndent = "└── ", "    "
+		}
+
+		label := node.Module + "@" + node.Version
+		if node.Requested != node.Version {
+			label += fmt.Sprintf(" (requires %s)", node.Requested)
+		}
+		if node.Replace != "" {
+			label += " => " + node.Replace
+		}
+		fmt.Fprintf(w, "%s%s%s\n", indent, branch, label)
+		printTree(w, node.Dependencies, indent+childIndent)
+	}
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func checkDepsOutput(output string) error {
+	if output != "text" && output != "json" {
+		return fmt.Errorf("unsupported output format %s", output)
+	}
+	return nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package project
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+func testDependencyGraph(t *testing.T) *dependencyGraph {
+	r := fakeResolver(
+		map[string][]string{
+			"stakpak://a":            {"v1.0.0", "v1.1.0", "v2.0.0"},
+			"stakpak://b":            {"v1.0.0", "v1.2.0", "v1.3.0-rc.1"},
+			"stakpak://c":            {"v0.1.0"},
+			"github.com/example/lib": {"v0.1.0", "v0.2.0"},
+		},
+		map[string]map[string]catalog.ModuleDependency{
+			"stakpak://a@v1.1.0": {
+				"stakpak://b": {V: version("^1")},
+				"stakpak://c": {V: version("v0.1.0")},
+			},
+			"stakpak://b@v1.2.0": {
+				"stakpak://c": {V: version("^0.1")},
+			},
+		},
+	)
+	r.replace = map[string]catalog.ModuleReplacement{
+		"github.com/example/lib": {Path: "../lib"},
+	}
+
+	g, err := newDependencyGraph(r, map[string]catalog.ModuleDependency{
+		"stakpak://a":            {V: version("^1.1")},
+		"stakpak://b":            {V: version(">=1.2")},
+		"github.com/example/lib": {V: version("v0.1.0")},
+	}, r.replace, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestDependencyTree(t *testing.T) {
+	nodes, err := testDependencyGraph(t).Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.Buffer{}
+	printTree(&buf, nodes, "")
+	expected := `├── github.com/example/lib@<untagged> (requires v0.1.0) => ../lib
+├── stakpak://a@v1.1.0 (requires ^1.1)
+│   ├── stakpak://b@v1.2.0 (requires ^1)
+│   │   └── stakpak://c@v0.1.0 (requires ^0.1)
+│   └── stakpak://c@v0.1.0
+└── stakpak://b@v1.2.0 (requires >=1.2)
+    └── stakpak://c@v0.1.0 (requires ^0.1)
+`
+	if buf.String() != expected {
+		t.Errorf("Expected tree\n%s\nbut found\n%s", expected, buf.String())
+	}
+}
+
+func TestDependencyOutdated(t *testing.T) {
+	g := testDependencyGraph(t)
+	// unpinned dependencies are never reported
+	g.resolver.versionsCache = map[string][]string{
+		"github.com/example/branch":   {"v1.0.0"},
+		"github.com/example/untagged": {"v1.0.0"},
+	}
+	g.selected["github.com/example/branch"] = catalog.ModuleDependency{V: version("main")}
+	g.selected["github.com/example/untagged"] = catalog.ModuleDependency{}
+
+	outdated, err := g.Outdated()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []OutdatedDependency{
+		{Module: "stakpak://a", Current: "v1.1.0", Latest: "v2.0.0"},
+	}
+	if len(outdated) != len(expected) {
+		t.Fatalf("Expected %v but found %v", expected, outdated)
+	}
+	for i := range expected {
+		if outdated[i] != expected[i] {
+			t.Errorf("Expected %v but found %v", expected[i], outdated[i])
+		}
+	}
+}
+
+func TestDependencyWhy(t *testing.T) {
+	g := testDependencyGraph(t)
+	reason, err := g.Why("stakpak://c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason.Version != "v0.1.0" {
+		t.Errorf("Expected stakpak://c to resolve to v0.1.0 but found %s", reason.Version)
+	}
+
+	paths := []string{}
+	for _, p := range reason.Paths {
+		paths = append(paths, strings.Join(p.Path, " -> ")+" requires "+p.Requires)
+	}
+	expected := []string{
+		"module.cue -> stakpak://a@v1.1.0 -> stakpak://c requires v0.1.0",
+		"module.cue -> stakpak://b@v1.2.0 -> stakpak://c requires ^0.1",
+	}
+	if strings.Join(paths, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected paths\n%s\nbut found\n%s", strings.Join(expected, "\n"), strings.Join(paths, "\n"))
+	}
+
+	if _, err := g.Why("stakpak://missing"); err == nil {
+		t.Errorf("Expected an error for a module that is not a dependency")
+	}
+}
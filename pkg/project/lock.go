@@ -0,0 +1,224 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+const (
+	LockFile    = "devx.lock"
+	lockVersion = 1
+)
+
+// Lock pins every direct and nested dependency of a project to the exact
+// revision vendored in cue.mod/pkg
+//
+//	{
+//		"version": 1,
+//		"deps": {
+//			"github.com/stakpak/devx-catalog": {
+//				"v": "v0.1.0",
+//				"commit": "3f1c...",
+//				"sum": "h1:...",
+//				"dirs": ["cue.mod/pkg/stakpak.dev/devx"]
+//			}
+//		}
+//	}
+type Lock struct {
+	Version      int                         `json:"version"`
+	Dependencies map[string]LockedDependency `json:"deps"`
+}
+
+type LockedDependency struct {
+	// V is the version requested in module.cue or by the parent package
+	V *string `json:"v,omitempty"`
+	// Version is the tag selected for a version constraint in V
+	Version string `json:"version,omitempty"`
+	// Replace is the local directory or repo the dependency is replaced with
+	Replace string `json:"replace,omitempty"`
+	// Commit is the resolved commit hash of git dependencies
+	Commit string `json:"commit,omitempty"`
+	// Digest is the content digest of stakpak:// packages
+	Digest string `json:"digest,omitempty"`
+	// Sum is the checksum of the files vendored in Dirs
+	Sum      string   `json:"sum"`
+	Dirs     []string `json:"dirs"`
+	Indirect bool     `json:"indirect,omitempty"`
+}
+
+func NewLock() *Lock {
+	return &Lock{
+		Version:      lockVersion,
+		Dependencies: map[string]LockedDependency{},
+	}
+}
+
+// ReadLock reads cue.mod/devx.lock, it returns nil if the project has no lock
+func ReadLock(configDir string) (*Lock, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, "cue.mod", LockFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lock := NewLock()
+	if err := json.Unmarshal(data, lock); err != nil {
+		return nil, fmt.Errorf("invalid cue.mod/%s: %s", LockFile, err)
+	}
+	if lock.Version != lockVersion {
+		return nil, fmt.Errorf("unsupported cue.mod/%s version %d", LockFile, lock.Version)
+	}
+	return lock, nil
+}
+
+func (l *Lock) Write(configDir string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(configDir, "cue.mod", LockFile), append(data, '\n'), 0600)
+}
+
+// Satisfies checks that the lock has an entry with the same version and
+// replacement for each dependency in module.cue and no direct dependencies
+// that were removed
+func (l *Lock) Satisfies(deps map[string]catalog.ModuleDependency, replace map[string]catalog.ModuleReplacement) error {
+	if l == nil {
+		return fmt.Errorf("cue.mod/%s not found, run devx project update", LockFile)
+	}
+
+	for name, dep := range deps {
+		locked, ok := l.Dependencies[name]
+		if !ok || locked.Indirect {
+			return fmt.Errorf("cue.mod/%s is out of date, %s is not locked, run devx project update", LockFile, name)
+		}
+		if versionString(locked.V) != versionString(dep.V) {
+			return fmt.Errorf(
+				"cue.mod/%s is out of date, %s is locked at %s but module.cue requires %s, run devx project update",
+				LockFile, name, versionString(locked.V), versionString(dep.V),
+			)
+		}
+	}
+	for name, locked := range l.Dependencies {
+		if _, ok := deps[name]; !ok && !locked.Indirect {
+			return fmt.Errorf("cue.mod/%s is out of date, %s was removed from module.cue, run devx project update", LockFile, name)
+		}
+
+		var replacement *catalog.ModuleReplacement
+		if r, ok := replace[name]; ok {
+			replacement = &r
+		}
+		if replaced := replacementString(replacement); replaced != locked.Replace {
+			return fmt.Errorf("cue.mod/%s is out of date, the replacement of %s changed, run devx project update", LockFile, name)
+		}
+	}
+	return nil
+}
+
+// Verify checks the vendored files of each dependency against the lock,
+// local replacements are not verified
+func (l *Lock) Verify(configDir string) error {
+	mismatched := []string{}
+	for name, locked := range l.Dependencies {
+		if isLocalPath(locked.Replace) {
+			continue
+		}
+		sum, err := dirSum(configDir, locked.Dirs)
+		if err != nil {
+			return err
+		}
+		if sum != locked.Sum {
+			mismatched = append(mismatched, name)
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("vendored packages do not match cue.mod/%s: %s", LockFile, strings.Join(mismatched, ", "))
+	}
+	return nil
+}
+
+// dirSum hashes the paths and contents of all files in dirs relative to
+// configDir, missing dirs are hashed as empty
+func dirSum(configDir string, dirs []string) (string, error) {
+	lines := []string{}
+	for _, dir := range dirs {
+		root := filepath.Join(configDir, filepath.FromSlash(dir))
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			content, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(configDir, p)
+			if err != nil {
+				return err
+			}
+			fileSum := sha256.Sum256(content)
+			lines = append(lines, fmt.Sprintf("%x  %s\n", fileSum, filepath.ToSlash(rel)))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, line := range lines {
+		h.Write([]byte(line))
+	}
+	return "h1:" + base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// sourceDigest is the content digest of a package fetched from the Hub
+func sourceDigest(source map[string]string) string {
+	files := make([]string, 0, len(source))
+	for file := range source {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	h := sha256.New()
+	for _, file := range files {
+		fileSum := sha256.Sum256([]byte(source[file]))
+		fmt.Fprintf(h, "%x  %s\n", fileSum, file)
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// resolved is the exact version installed for a locked dependency
+func (d LockedDependency) resolved() *string {
+	if d.Version != "" {
+		return &d.Version
+	}
+	return d.V
+}
+
+func versionString(v *string) string {
+	if v == nil {
+		return "<untagged>"
+	}
+	return *v
+}
@@ -0,0 +1,153 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) {
+	filePath := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockVerify(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "cue.mod/pkg/example.com/lib/lib.cue", "package lib\n")
+
+	sum, err := dirSum(dir, []string{"cue.mod/pkg/example.com/lib"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := "v1.0.0"
+	lock := NewLock()
+	lock.Dependencies["github.com/example/lib"] = LockedDependency{
+		V:      &version,
+		Commit: "3f1c0de",
+		Sum:    sum,
+		Dirs:   []string{"cue.mod/pkg/example.com/lib"},
+	}
+	if err := lock.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = ReadLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Verify(dir); err != nil {
+		t.Errorf("Expected vendored files to match the lock but found %s", err)
+	}
+
+	writeFile(t, dir, "cue.mod/pkg/example.com/lib/lib.cue", "package lib\n\nx: 1\n")
+	if err := lock.Verify(dir); err == nil || !strings.Contains(err.Error(), "github.com/example/lib") {
+		t.Errorf("Expected modified vendored files to be reported but found %v", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(dir, "cue.mod", "pkg")); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Verify(dir); err == nil {
+		t.Errorf("Expected missing vendored files to be reported")
+	}
+}
+
+func TestLockSatisfies(t *testing.T) {
+	v1, v2 := "v1.0.0", "v2.0.0"
+	lock := NewLock()
+	lock.Dependencies["github.com/example/lib"] = LockedDependency{V: &v1}
+	lock.Dependencies["stakpak://nested"] = LockedDependency{Indirect: true}
+
+	tests := []struct {
+		deps  map[string]catalog.ModuleDependency
+		error string
+	}{
+		{map[string]catalog.ModuleDependency{"github.com/example/lib": {V: &v1}}, ""},
+		{map[string]catalog.ModuleDependency{"github.com/example/lib": {V: &v2}}, "locked at v1.0.0 but module.cue requires v2.0.0"},
+		{map[string]catalog.ModuleDependency{"github.com/example/lib": {V: &v1}, "github.com/example/new": {}}, "github.com/example/new is not locked"},
+		{map[string]catalog.ModuleDependency{"github.com/example/lib": {V: &v1}, "stakpak://nested": {}}, "stakpak://nested is not locked"},
+		{map[string]catalog.ModuleDependency{}, "github.com/example/lib was removed"},
+	}
+	for _, test := range tests {
+		err := lock.Satisfies(test.deps, nil)
+		if test.error == "" && err != nil {
+			t.Errorf("Expected lock to satisfy %v but found %s", test.deps, err)
+		}
+		if test.error != "" && (err == nil || !strings.Contains(err.Error(), test.error)) {
+			t.Errorf("Expected error containing %q but found %v", test.error, err)
+		}
+	}
+
+	deps := map[string]catalog.ModuleDependency{"github.com/example/lib": {V: &v1}}
+	replace := map[string]catalog.ModuleReplacement{"github.com/example/lib": {Path: "../lib"}}
+	if err := lock.Satisfies(deps, replace); err == nil || !strings.Contains(err.Error(), "replacement of github.com/example/lib changed") {
+		t.Errorf("Expected a new replacement to be reported but found %v", err)
+	}
+
+	var missing *Lock
+	if err := missing.Satisfies(map[string]catalog.ModuleDependency{}, nil); err == nil {
+		t.Errorf("Expected a missing lock to be reported")
+	}
+}
+
+func TestSourceDigest(t *testing.T) {
+	a := sourceDigest(map[string]string{"a.cue": "package a", "b.cue": "package a"})
+	b := sourceDigest(map[string]string{"b.cue": "package a", "a.cue": "package a"})
+	if a != b {
+		t.Errorf("Expected digest to be independent of map order")
+	}
+	if c := sourceDigest(map[string]string{"a.cue": "package a", "b.cue": "package b"}); c == a {
+		t.Errorf("Expected digest to change with content")
+	}
+}
+
+func TestEnsureDependenciesLock(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "cue.mod/module.cue", "module: \"example.com/app\"\n")
+	if err := EnsureDependencies(dir, auth.ServerConfig{}); err != nil {
+		t.Errorf("Expected a project without dependencies to need no lock but found %s", err)
+	}
+
+	// a missing lock is created
+	writeFile(t, dir, "lib/cue.mod/module.cue", "module: \"example.com/lib\"\n")
+	writeFile(t, dir, "lib/lib.cue", "package lib\n")
+	writeFile(t, dir, "cue.mod/module.cue", "module: \"example.com/app\"\ndeps: \"github.com/example/lib\": {}\nreplace: \"github.com/example/lib\": path: \"./lib\"\n")
+	if err := EnsureDependencies(dir, auth.ServerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	if lock, err := ReadLock(dir); err != nil || lock == nil {
+		t.Errorf("Expected the lock to be created but found %v", err)
+	}
+
+	writeFile(t, dir, "cue.mod/module.cue", "module: \"example.com/app\"\ndeps: \"github.com/example/lib\": v: \"v1.0.0\"\n")
+
+	v2 := "v2.0.0"
+	lock := NewLock()
+	lock.Dependencies["github.com/example/lib"] = LockedDependency{V: &v2}
+	if err := lock.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(filepath.Join(dir, "cue.mod", LockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := EnsureDependencies(dir, auth.ServerConfig{}); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("Expected an outdated lock to be reported but found %v", err)
+	}
+	after, err := os.ReadFile(filepath.Join(dir, "cue.mod", LockFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(before) != string(after) {
+		t.Errorf("Expected the lock to be left untouched but found\n%s", after)
+	}
+}
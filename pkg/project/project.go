@@ -3,13 +3,13 @@ package project
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"cuelang.org/go/cue"
@@ -18,13 +18,16 @@ import (
 	"github.com/go-git/go-billy/v5"
 	"github.com/go-git/go-billy/v5/memfs"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/memory"
 	log "github.com/sirupsen/logrus"
 	"github.com/stakpak/devx/pkg/auth"
 	"github.com/stakpak/devx/pkg/catalog"
+	"github.com/stakpak/devx/pkg/diagnostics"
 	"github.com/stakpak/devx/pkg/gitrepo"
 	"github.com/stakpak/devx/pkg/stack"
 	"github.com/stakpak/devx/pkg/stackbuilder"
@@ -34,47 +37,104 @@ import (
 
 const stakpakPrefix = "stakpak://"
 
-func Validate(configDir string, stackPath string, buildersPath string, noStrict bool) error {
+func Validate(configDir string, stackPath string, buildersPath string, noStrict bool, output string) error {
 	overlays, err := utils.GetOverlays(configDir)
 	if err != nil {
 		return err
 	}
 
-	value, _, _ := utils.LoadProject(configDir, &overlays)
-	if err := ValidateProject(value, stackPath, buildersPath, noStrict); err != nil {
+	value, _, _ := utils.LoadProject(configDir, &overlays, "")
+	err = ValidateProject(value, stackPath, buildersPath, noStrict)
+
+	result := diagnostics.FromError(err, "cue")
+	envResult, err := validateEnvironments(configDir, &overlays, stackPath, buildersPath, noStrict, result)
+	if err != nil {
 		return err
 	}
+	result = append(result, envResult...)
+	result.RelativeTo(configDir)
+	if output == "text" {
+		if len(result) > 0 {
+			return result
+		}
+		log.Info("👌 All looks good")
+		return nil
+	}
 
-	log.Info("👌 All looks good")
+	if err := diagnostics.Print(os.Stdout, result, output); err != nil {
+		return err
+	}
+	if result.HasErrors() {
+		return fmt.Errorf("validation failed with %d diagnostic(s)", len(result))
+	}
 	return nil
 }
 
+// validateEnvironments validates the project with the overlay of each
+// environment in utils.EnvironmentsDir, diagnostics already found without an
+// environment are not repeated
+func validateEnvironments(configDir string, overlays *map[string]string, stackPath string, buildersPath string, noStrict bool, found diagnostics.Diagnostics) (diagnostics.Diagnostics, error) {
+	environments, err := utils.EnvironmentOverlays(configDir, overlays)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[diagnostics.Diagnostic]bool{}
+	for _, diagnostic := range found {
+		seen[diagnostic] = true
+	}
+	result := diagnostics.Diagnostics{}
+	for _, environment := range environments {
+		if err := utils.ValidateEnvironment(environment); err != nil {
+			return nil, err
+		}
+		value, _, _ := utils.LoadProject(configDir, overlays, environment)
+		for _, diagnostic := range diagnostics.FromError(ValidateProject(value, stackPath, buildersPath, noStrict), "cue") {
+			if seen[diagnostic] {
+				continue
+			}
+			seen[diagnostic] = true
+			diagnostic.Environment = environment
+			result = append(result, diagnostic)
+		}
+	}
+	return result, nil
+}
+
+// ValidateProject validates the project configurations, validation failures
+// are returned as diagnostics.Diagnostics
 func ValidateProject(value cue.Value, stackPath string, buildersPath string, noStrict bool) error {
 	err := value.Validate()
 	if err != nil {
-		return err
+		return attributeDiagnostics(diagnostics.FromError(err, "cue"), stackPath, buildersPath)
 	}
 
 	stackValue := value.LookupPath(cue.ParsePath(stackPath))
 	if stackValue.Err() != nil {
-		return stackValue.Err()
+		return diagnostics.FromError(stackValue.Err(), "cue")
 	}
 
-	isValid := true
-	err = errors.New("invalid Components")
+	result := diagnostics.Diagnostics{}
 	utils.Walk(stackValue, func(v cue.Value) bool {
 		gukuAttr := v.Attribute("guku")
 
 		isRequired, _ := gukuAttr.Flag(0, "required")
 		if isRequired && !v.IsConcrete() && !utils.IsReference(v) {
-			isValid = false
-			err = fmt.Errorf("%w\n%s is a required field", err, v.Path())
+			diagnostic := diagnostics.Diagnostic{
+				Severity: diagnostics.Error,
+				Rule:     "required-field",
+				Path:     v.Path().String(),
+				Message:  "required field is not set",
+			}
+			diagnostic.SetPosition(v.Pos())
+			result = append(result, diagnostic)
 		}
 		return true
 	}, nil)
 
-	if !isValid {
-		return err
+	if len(result) > 0 {
+		result.Sort()
+		return attributeDiagnostics(result, stackPath, buildersPath)
 	}
 
 	if noStrict {
@@ -83,17 +143,33 @@ func ValidateProject(value cue.Value, stackPath string, buildersPath string, noS
 
 	builders, err := stackbuilder.NewEnvironments(value.LookupPath(cue.ParsePath(buildersPath)))
 	if err != nil {
-		return err
+		return attributeDiagnostics(diagnostics.FromError(err, "cue"), stackPath, buildersPath)
 	}
 
 	stack, err := stack.NewStack(stackValue, "", []string{})
 	if err != nil {
-		return err
+		return diagnostics.FromError(err, "stack")
 	}
 
 	return stackbuilder.CheckTraitFulfillment(builders, stack)
 }
 
+// attributeDiagnostics sets the component or environment of diagnostics
+// based on their path in the project
+func attributeDiagnostics(result diagnostics.Diagnostics, stackPath string, buildersPath string) diagnostics.Diagnostics {
+	componentsPrefix := stackPath + ".components."
+	buildersPrefix := buildersPath + "."
+	for i, diagnostic := range result {
+		switch {
+		case strings.HasPrefix(diagnostic.Path, componentsPrefix):
+			result[i].Component = strings.SplitN(strings.TrimPrefix(diagnostic.Path, componentsPrefix), ".", 2)[0]
+		case strings.HasPrefix(diagnostic.Path, buildersPrefix):
+			result[i].Environment = strings.SplitN(strings.TrimPrefix(diagnostic.Path, buildersPrefix), ".", 2)[0]
+		}
+	}
+	return result
+}
+
 func Discover(configDir string, showDefs bool, showTransformers bool) error {
 	overlays, err := utils.GetOverlays(configDir)
 	if err != nil {
@@ -202,27 +278,176 @@ builders: v2alpha1.#Environments & {
 	return nil
 }
 
-func Update(configDir string, server auth.ServerConfig) error {
+// Update resolves the dependencies in cue.mod/module.cue, vendors them in
+// cue.mod/pkg and records the resolved revisions in cue.mod/devx.lock. With
+// frozen the locked revisions are installed instead and the lock must be up
+// to date with module.cue
+func Update(configDir string, server auth.ServerConfig, frozen bool) error {
+	ctx, deps, replace, err := readModuleDependencies(configDir)
+	if err != nil {
+		return err
+	}
+
+	lock, err := ReadLock(configDir)
+	if err != nil {
+		return err
+	}
+
+	allDeps := map[string]catalog.ModuleDependency{}
+	if frozen {
+		if err := lock.Satisfies(deps, replace); err != nil {
+			return err
+		}
+		for name, locked := range lock.Dependencies {
+			allDeps[name] = catalog.ModuleDependency{V: locked.resolved()}
+		}
+	} else {
+		allDeps, err = resolveDependencies(server, deps, replace)
+		if err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(allDeps))
+	for name := range allDeps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	newLock := NewLock()
+	for _, name := range names {
+		var locked *LockedDependency
+		if frozen {
+			entry := lock.Dependencies[name]
+			locked = &entry
+		}
+
+		var entry LockedDependency
+		if strings.HasPrefix(name, stakpakPrefix) {
+			entry, err = updateHubPackage(configDir, server, name, allDeps[name], locked)
+		} else {
+			var replacement *catalog.ModuleReplacement
+			if r, ok := replace[name]; ok {
+				replacement = &r
+			}
+			entry, err = updateGitPackage(configDir, ctx, name, allDeps[name], replacement, locked)
+			entry.Replace = replacementString(replacement)
+		}
+		if err != nil {
+			return err
+		}
+
+		entry.V = allDeps[name].V
+		direct, isDirect := deps[name]
+		if isDirect && versionString(direct.V) != versionString(entry.V) {
+			entry.Version = versionString(entry.V)
+			entry.V = direct.V
+		}
+		entry.Indirect = !isDirect
+		newLock.Dependencies[name] = entry
+	}
+
+	// packages may vendor into the same directories, so sums are computed
+	// once all of them are written
+	for name, entry := range newLock.Dependencies {
+		entry.Sum, err = dirSum(configDir, entry.Dirs)
+		if err != nil {
+			return err
+		}
+		// local replacements are expected to change while they are developed
+		if frozen && !isLocalPath(entry.Replace) && entry.Sum != lock.Dependencies[name].Sum {
+			return fmt.Errorf("checksum mismatch for %s, vendored files do not match cue.mod/%s", name, LockFile)
+		}
+		newLock.Dependencies[name] = entry
+	}
+
+	if frozen {
+		return nil
+	}
+	return newLock.Write(configDir)
+}
+
+// EnsureDependencies prepares cue.mod/pkg before a build. Projects without a
+// lock are updated to create it, otherwise the lock must be up to date with
+// module.cue and the dependencies are reinstalled from the lock if the
+// vendored files do not match it
+func EnsureDependencies(configDir string, server auth.ServerConfig) error {
+	_, deps, replace, err := readModuleDependencies(configDir)
+	if err != nil {
+		return err
+	}
+	lock, err := ReadLock(configDir)
+	if err != nil {
+		return err
+	}
+	if lock == nil && len(deps) == 0 && len(replace) == 0 {
+		return nil
+	}
+	if lock == nil {
+		log.Infof("📦 cue.mod/%s not found, installing dependencies", LockFile)
+		return Update(configDir, server, false)
+	}
+	if err := lock.Satisfies(deps, replace); err != nil {
+		return err
+	}
+
+	if err := lock.Verify(configDir); err != nil {
+		log.Infof("📦 %s, restoring locked versions", err)
+		return Update(configDir, server, true)
+	}
+	return copyLocalReplacements(configDir, lock, replace)
+}
+
+// copyLocalReplacements vendors the locked dependencies replaced with local
+// directories again, other dependencies are left untouched
+func copyLocalReplacements(configDir string, lock *Lock, replace map[string]catalog.ModuleReplacement) error {
+	ctx := cuecontext.New()
+	for name, locked := range lock.Dependencies {
+		if !isLocalPath(locked.Replace) {
+			continue
+		}
+		replacement := replace[name]
+		if _, err := updateGitPackage(configDir, ctx, name, catalog.ModuleDependency{V: locked.resolved()}, &replacement, &locked); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readModuleDependencies reads the direct dependencies and replacements in
+// cue.mod/module.cue, the legacy packages list is migrated to deps
+func readModuleDependencies(configDir string) (*cue.Context, map[string]catalog.ModuleDependency, map[string]catalog.ModuleReplacement, error) {
 	cuemodulePath := path.Join(configDir, "cue.mod", "module.cue")
 	data, err := os.ReadFile(cuemodulePath)
 	if err != nil {
-		return err
+		return nil, nil, nil, err
 	}
 
 	ctx := cuecontext.New()
 	cuemodule := ctx.CompileBytes(data)
 	if cuemodule.Err() != nil {
-		return cuemodule.Err()
+		return nil, nil, nil, cuemodule.Err()
 	}
 
 	deps := map[string]catalog.ModuleDependency{}
+	replace := map[string]catalog.ModuleReplacement{}
+
+	replaceValue := cuemodule.LookupPath(cue.ParsePath("replace"))
+	if replaceValue.Exists() {
+		if err := replaceValue.Decode(&replace); err != nil {
+			return nil, nil, nil, err
+		}
+		if err := validateReplacements(replace); err != nil {
+			return nil, nil, nil, err
+		}
+	}
 
 	oldPackages := cuemodule.LookupPath(cue.ParsePath("packages"))
 	if oldPackages.Exists() {
 		packages := []string{}
 		err = oldPackages.Decode(&packages)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 
 		for _, pkg := range packages {
@@ -243,10 +468,10 @@ func Update(configDir string, server auth.ServerConfig) error {
 
 		moduleName, err := cuemodule.LookupPath(cue.ParsePath("module")).String()
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
-		if err := updateModuleFile(configDir, ctx, moduleName, deps); err != nil {
-			return err
+		if err := updateModuleFile(configDir, ctx, moduleName, deps, replace); err != nil {
+			return nil, nil, nil, err
 		}
 
 		log.Info("Updated module.cue format")
@@ -255,218 +480,347 @@ func Update(configDir string, server auth.ServerConfig) error {
 	depsValue := cuemodule.LookupPath(cue.ParsePath("deps"))
 	if depsValue.Exists() {
 		if depsValue.Err() != nil {
-			return depsValue.Err()
+			return nil, nil, nil, depsValue.Err()
 		}
 
 		err = depsValue.Decode(&deps)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 	}
 
-	allDeps, err := resolveNestedDependencies(server, deps, 0)
+	return ctx, deps, replace, nil
+}
+
+// updateHubPackage vendors a stakpak:// package, a locked package must have
+// the same content digest it had when it was locked
+func updateHubPackage(configDir string, server auth.ServerConfig, name string, pkg catalog.ModuleDependency, locked *LockedDependency) (LockedDependency, error) {
+	name = strings.TrimPrefix(name, stakpakPrefix)
+	packageItem, err := fetchHubPackage(server, name, pkg.V)
 	if err != nil {
-		return err
+		return LockedDependency{}, err
 	}
 
-	for name, pkg := range allDeps {
-		if strings.HasPrefix(name, stakpakPrefix) {
-			name = strings.TrimPrefix(name, stakpakPrefix)
-			queryParams := map[string]string{
-				"name": name,
-			}
+	digest := sourceDigest(packageItem.Source)
+	if locked != nil && locked.Digest != digest {
+		return LockedDependency{}, fmt.Errorf("package %s@%s changed since it was locked", name, versionString(pkg.V))
+	}
 
-			if pkg.V != nil {
-				queryParams["version"] = *pkg.V
-			}
+	installedVersion := "<untagged>"
+	if len(packageItem.Tags) > 0 {
+		installedVersion = packageItem.Tags[0]
+	}
 
-			data, err := utils.GetData(
-				server,
-				path.Join("package", "fetch"),
-				nil,
-				queryParams,
-			)
-			if err != nil {
-				return err
-			}
-			packageItem := catalog.ModuleItem{}
-			err = json.Unmarshal(data, &packageItem)
-			if err != nil {
-				return err
-			}
+	log.Infof("📦 Updating %s@%s", name, installedVersion)
 
-			installedVersion := "<untagged>"
-			if len(packageItem.Tags) > 0 {
-				installedVersion = packageItem.Tags[0]
-			}
+	pkgDir := path.Join(configDir, "cue.mod", "pkg", name)
+	err = os.RemoveAll(pkgDir)
+	if err != nil {
+		return LockedDependency{}, err
+	}
 
-			log.Infof("📦 Updating %s@%s", name, installedVersion)
+	for filePath, content := range packageItem.Source {
+		writePath := filepath.Join(pkgDir, filePath)
+		writeDirPath := filepath.Dir(writePath)
+		if err := os.MkdirAll(writeDirPath, 0755); err != nil {
+			return LockedDependency{}, err
+		}
+		if err := os.WriteFile(writePath, []byte(content), 0700); err != nil {
+			return LockedDependency{}, err
+		}
+	}
 
-			pkgDir := path.Join(configDir, "cue.mod", "pkg", name)
-			err = os.RemoveAll(pkgDir)
-			if err != nil {
-				return err
-			}
+	return LockedDependency{
+		Digest: digest,
+		Dirs:   []string{path.Join("cue.mod", "pkg", name)},
+	}, nil
+}
 
-			for path, content := range packageItem.Source {
-				writePath := filepath.Join(pkgDir, path)
-				writeDirPath := filepath.Dir(writePath)
-				if err := os.MkdirAll(writeDirPath, 0755); err != nil {
-					return err
-				}
-				if err != os.WriteFile(writePath, []byte(content), 0700) {
-					return err
-				}
-			}
-			continue
-		}
+// updateGitPackage vendors a git dependency at its requested revision or at
+// the locked commit, replaced dependencies are vendored from the replacement
+func updateGitPackage(configDir string, ctx *cue.Context, name string, pkg catalog.ModuleDependency, replacement *catalog.ModuleReplacement, locked *LockedDependency) (LockedDependency, error) {
+	repoRevision := "main"
+	if pkg.V != nil {
+		repoRevision = *pkg.V
+	}
+	repoPath := ""
+
+	var fs billy.Filesystem
+	var hash plumbing.Hash
+	var err error
+	switch {
+	case replacement != nil && replacement.Path != "":
+		fs, err = localPackage(configDir, replacement.Path)
+	case replacement != nil:
+		fs, hash, err = checkoutGitPackage(replacement.Repo, repoRevision, locked)
+	default:
+		fs, hash, err = checkoutGitPackage(name, repoRevision, locked)
+	}
+	if err != nil {
+		return LockedDependency{}, err
+	}
 
-		repoURL := "https://" + name
-		repoRevision := "main"
-		if pkg.V != nil {
-			repoRevision = *pkg.V
-		}
-		repoPath := ""
+	entry := LockedDependency{
+		Dirs: []string{},
+	}
+	switch {
+	case replacement != nil && replacement.Path != "":
+		log.Infof("📦 Updating %s => %s", name, replacement.Path)
+	case replacement != nil:
+		log.Infof("📦 Updating %s => %s@%s", name, replacement.Repo, hash)
+		entry.Commit = hash.String()
+	default:
+		log.Infof("📦 Updating %s@%s", name, hash)
+		entry.Commit = hash.String()
+	}
 
-		repo, mfs, err := getRepo(repoURL)
+	moduleFilePath := filepath.Join("cue.mod", "module.cue")
+	_, err = fs.Lstat(moduleFilePath)
+	if err == nil {
+		content, err := fs.Open(moduleFilePath)
 		if err != nil {
-			return err
+			return LockedDependency{}, err
 		}
-
-		hash, err := repo.ResolveRevision(plumbing.Revision(repoRevision))
+		moduleData, err := io.ReadAll(bufio.NewReader(content))
 		if err != nil {
-			return err
+			return LockedDependency{}, err
+		}
+		module := ctx.CompileBytes(moduleData)
+		moduleName := module.LookupPath(cue.ParsePath("module"))
+		if moduleName.Err() != nil {
+			return LockedDependency{}, moduleName.Err()
 		}
 
-		log.Infof("📦 Updating %s@%s", name, hash)
-
-		w, err := repo.Worktree()
+		modulePrefix, err := moduleName.String()
 		if err != nil {
-			return err
+			return LockedDependency{}, err
 		}
 
-		err = w.Checkout(&git.CheckoutOptions{
-			Hash: *hash,
-		})
+		log.Debug("Module prefix: ", modulePrefix)
+		pkgDir := path.Join(configDir, "cue.mod", "pkg", modulePrefix)
+		pkgSubDir := path.Join(pkgDir, repoPath)
+		log.Debug("Updating package ", pkgSubDir)
+		err = os.RemoveAll(pkgSubDir)
 		if err != nil {
-			return err
+			return LockedDependency{}, err
 		}
-
-		moduleFilePath := filepath.Join("cue.mod", "module.cue")
-		_, err = (*mfs).Lstat(moduleFilePath)
-		if err == nil {
-			content, err := (*mfs).Open(moduleFilePath)
-			if err != nil {
-				return err
-			}
-			moduleData, err := io.ReadAll(bufio.NewReader(content))
-			if err != nil {
-				return err
-			}
-			module := ctx.CompileBytes(moduleData)
-			moduleName := module.LookupPath(cue.ParsePath("module"))
-			if moduleName.Err() != nil {
-				return moduleName.Err()
-			}
-
-			modulePrefix, err := moduleName.String()
-			if err != nil {
-				return err
+		entry.Dirs = append(entry.Dirs, path.Join("cue.mod", "pkg", modulePrefix, repoPath))
+
+		err = utils.FsWalk(fs, repoPath, func(file string, content []byte) error {
+			if strings.HasPrefix(file, ".") ||
+				strings.HasPrefix(file, "cue.mod") ||
+				// strings.HasPrefix(file, "pkg") ||
+				!strings.HasSuffix(file, ".cue") {
+				return nil
 			}
 
-			log.Debug("Module prefix: ", modulePrefix)
-			pkgDir := path.Join(configDir, "cue.mod", "pkg", modulePrefix)
-			pkgSubDir := path.Join(pkgDir, repoPath)
-			log.Debug("Updating package ", pkgSubDir)
-			err = os.RemoveAll(pkgSubDir)
-			if err != nil {
+			writePath := path.Join(pkgDir, file)
+			if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
 				return err
 			}
+			return os.WriteFile(writePath, content, 0700)
+		})
+		if err != nil {
+			return LockedDependency{}, err
+		}
 
-			err = utils.FsWalk(*mfs, repoPath, func(file string, content []byte) error {
-				if strings.HasPrefix(file, ".") ||
-					strings.HasPrefix(file, "cue.mod") ||
-					// strings.HasPrefix(file, "pkg") ||
-					!strings.HasSuffix(file, ".cue") {
-					return nil
-				}
-
-				writePath := path.Join(pkgDir, file)
-				if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
-					return err
-				}
-				return os.WriteFile(writePath, content, 0700)
-			})
+		log.Debugf("Updating packages %s dependencies", pkgDir)
+		if strings.HasPrefix(modulePrefix, "stakpak.dev/devx") {
+			moduleDepPkgPath := path.Join("cue.mod", "pkg")
+			packageInfo, err := fs.ReadDir(moduleDepPkgPath)
 			if err != nil {
-				return err
+				return LockedDependency{}, err
 			}
 
-			log.Debugf("Updating packages %s dependencies", pkgDir)
-			if strings.HasPrefix(modulePrefix, "stakpak.dev/devx") {
-				moduleDepPkgPath := path.Join("cue.mod", "pkg")
-				packageInfo, err := (*mfs).ReadDir(moduleDepPkgPath)
+			for _, info := range packageInfo {
+				modPkgDir := path.Join(moduleDepPkgPath, info.Name())
+				pkgDir := path.Join(configDir, modPkgDir)
+				log.Debug("Updating dependency ", modPkgDir)
+				err = os.RemoveAll(pkgDir)
 				if err != nil {
-					return err
+					return LockedDependency{}, err
 				}
+				entry.Dirs = append(entry.Dirs, modPkgDir)
 
-				for _, info := range packageInfo {
-					modPkgDir := path.Join(moduleDepPkgPath, info.Name())
-					pkgDir := path.Join(configDir, modPkgDir)
-					log.Debug("Updating dependency ", modPkgDir)
-					err = os.RemoveAll(pkgDir)
-					if err != nil {
-						return err
-					}
-
-					err = utils.FsWalk(*mfs, modPkgDir, func(file string, content []byte) error {
-						writePath := path.Join(configDir, file)
-						if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
-							return err
-						}
-						return os.WriteFile(writePath, content, 0700)
-					})
-					if err != nil {
+				err = utils.FsWalk(fs, modPkgDir, func(file string, content []byte) error {
+					writePath := path.Join(configDir, file)
+					if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
 						return err
 					}
+					return os.WriteFile(writePath, content, 0700)
+				})
+				if err != nil {
+					return LockedDependency{}, err
 				}
 			}
-
-			continue
 		}
 
-		// fallback to legacy package management
-		packageInfo, err := (*mfs).ReadDir(repoPath)
+		return entry, nil
+	}
+
+	// fallback to legacy package management
+	packageInfo, err := fs.ReadDir(repoPath)
+	if err != nil {
+		return LockedDependency{}, err
+	}
+
+	for _, info := range packageInfo {
+		pkgDir := path.Join(configDir, "cue.mod", repoPath, info.Name())
+		err = os.RemoveAll(pkgDir)
 		if err != nil {
-			return err
+			return LockedDependency{}, err
 		}
+		entry.Dirs = append(entry.Dirs, path.Join("cue.mod", repoPath, info.Name()))
+	}
 
-		for _, info := range packageInfo {
-			pkgDir := path.Join(configDir, "cue.mod", repoPath, info.Name())
-			err = os.RemoveAll(pkgDir)
-			if err != nil {
-				return err
-			}
+	err = utils.FsWalk(fs, repoPath, func(file string, content []byte) error {
+		writePath := path.Join(configDir, "cue.mod", file)
+
+		if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
+			return err
 		}
 
-		err = utils.FsWalk(*mfs, repoPath, func(file string, content []byte) error {
-			writePath := path.Join(configDir, "cue.mod", file)
+		return os.WriteFile(writePath, content, 0700)
+	})
+	if err != nil {
+		return LockedDependency{}, err
+	}
 
-			if err := os.MkdirAll(filepath.Dir(writePath), 0755); err != nil {
-				return err
-			}
+	return entry, nil
+}
 
-			return os.WriteFile(writePath, content, 0700)
-		})
+// checkoutGitPackage returns the worktree of a git dependency at revision or
+// at the locked commit, source is a module path or a git URL. Worktrees are
+// read from the module cache when the commit is known upfront and only
+// cloned on a cache miss
+func checkoutGitPackage(source string, revision string, locked *LockedDependency) (billy.Filesystem, plumbing.Hash, error) {
+	repoURL := gitRepoURL(source)
+	name := modulePathFromURL(source)
+
+	cache, err := newModuleCache()
+	if err != nil {
+		log.Debugf("Module cache disabled: %s", err)
+	}
 
+	knownHash := ""
+	switch {
+	case locked != nil:
+		knownHash = locked.Commit
+	case plumbing.IsHash(revision):
+		knownHash = revision
+	case cache != nil:
+		knownHash, err = resolveRemoteRevision(repoURL, revision)
 		if err != nil {
-			return err
+			log.Debugf("Failed to resolve %s@%s from the remote: %s", name, revision, err)
+		}
+	}
+	if cache != nil && knownHash != "" {
+		if fs, commit, ok := cache.get(name, knownHash); ok {
+			log.Debugf("Using cached %s@%s", name, commit)
+			return fs, commit, nil
 		}
 	}
 
-	return nil
+	repo, mfs, repoAuth, err := getRepo(repoURL)
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	var hash *plumbing.Hash
+	if locked != nil {
+		hash, err = fetchCommit(repo, repoAuth, locked.Commit)
+	} else {
+		hash, err = resolveRevision(repo, repoAuth, revision)
+	}
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	w, err := repo.Worktree()
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+	err = w.Checkout(&git.CheckoutOptions{
+		Hash: *hash,
+	})
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	if cache == nil {
+		return *mfs, *hash, nil
+	}
+	fs, err := cache.put(name, *hash, *mfs)
+	if err != nil {
+		log.Debug(err)
+		return *mfs, *hash, nil
+	}
+	if err := cache.alias(name, knownHash, *hash); err != nil {
+		log.Debug(err)
+	}
+	return fs, *hash, nil
+}
+
+// resolveRemoteRevision resolves a branch or tag to the hash advertised by
+// the remote, an empty hash is returned if the remote has no such ref
+func resolveRemoteRevision(repoURL string, revision string) (string, error) {
+	refs, err := listRemoteRefs(repoURL)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []plumbing.ReferenceName{
+		plumbing.NewBranchReferenceName(revision),
+		plumbing.NewTagReferenceName(revision),
+	} {
+		for _, ref := range refs {
+			if ref.Name() == name {
+				return ref.Hash().String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// fetchCommit fetches a commit that is not part of the shallow clone, such as
+// a locked commit the branch moved away from
+func fetchCommit(repo *git.Repository, repoAuth transport.AuthMethod, commit string) (*plumbing.Hash, error) {
+	hash := plumbing.NewHash(commit)
+	if _, err := repo.CommitObject(hash); err == nil {
+		return &hash, nil
+	}
+
+	err := repo.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:refs/devx/locked", commit))},
+		Depth:    1,
+		Auth:     repoAuth,
+	})
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("failed to fetch locked commit %s: %s", commit, err)
+	}
+	return &hash, nil
 }
 
-func getRepo(repoURL string) (*git.Repository, *billy.Filesystem, error) {
+// resolveRevision resolves a branch, commit or tag, tags outside the shallow
+// clone such as resolved version constraints are fetched first
+func resolveRevision(repo *git.Repository, repoAuth transport.AuthMethod, revision string) (*plumbing.Hash, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(revision))
+	if err == nil {
+		return hash, nil
+	}
+
+	tagRef := plumbing.NewTagReferenceName(revision)
+	fetchErr := repo.Fetch(&git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", tagRef, tagRef))},
+		Depth:    1,
+		Auth:     repoAuth,
+	})
+	if fetchErr != nil && fetchErr != git.NoErrAlreadyUpToDate {
+		return nil, err
+	}
+	return repo.ResolveRevision(plumbing.Revision(tagRef))
+}
+
+func getRepo(repoURL string) (*git.Repository, *billy.Filesystem, transport.AuthMethod, error) {
 	// try without auth
 	mfs := memfs.New()
 	storer := memory.NewStorage()
@@ -476,64 +830,56 @@ func getRepo(repoURL string) (*git.Repository, *billy.Filesystem, error) {
 		Depth:      1,
 	})
 	if err == nil {
-		return repo, &mfs, nil
+		return repo, &mfs, nil, nil
 	}
 	if err.Error() != "authentication required" {
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	repoAuth, err := gitAuth()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	// fetch credentials
+	mfs = memfs.New()
+	storer = memory.NewStorage()
+	repo, err = git.Clone(storer, mfs, &git.CloneOptions{
+		URL:   repoURL,
+		Auth:  repoAuth,
+		Depth: 1,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return repo, &mfs, repoAuth, nil
+}
+
+// gitAuth reads the credentials for private repos from the environment
+func gitAuth() (transport.AuthMethod, error) {
 	gitUsername := os.Getenv("GIT_USERNAME")
 	gitPassword := os.Getenv("GIT_PASSWORD")
 	gitPrivateKeyFile := os.Getenv("GIT_PRIVATE_KEY_FILE")
 	gitPrivateKeyFilePassword := os.Getenv("GIT_PRIVATE_KEY_FILE_PASSWORD")
 
 	if gitPrivateKeyFile == "" && gitPassword == "" {
-		return nil, nil, fmt.Errorf(`To access private repos please provide
+		return nil, fmt.Errorf(`To access private repos please provide
 GIT_USERNAME & GIT_PASSWORD
 or
 GIT_PRIVATE_KEY_FILE & GIT_PRIVATE_KEY_FILE_PASSWORD`)
 	}
 
 	if gitPassword != "" {
-		auth := http.BasicAuth{
+		return &http.BasicAuth{
 			Username: gitUsername,
 			Password: gitPassword,
-		}
-
-		mfs = memfs.New()
-		storer = memory.NewStorage()
-		repo, err = git.Clone(storer, mfs, &git.CloneOptions{
-			URL:   repoURL,
-			Auth:  &auth,
-			Depth: 1,
-		})
-		if err != nil {
-			return nil, nil, err
-		}
-		return repo, &mfs, nil
+		}, nil
 	}
 
-	if gitPrivateKeyFile != "" {
-		publicKeys, err := ssh.NewPublicKeysFromFile("git", gitPrivateKeyFile, gitPrivateKeyFilePassword)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to use git private key %s: %s", gitPrivateKeyFile, err.Error())
-		}
-
-		mfs = memfs.New()
-		storer = memory.NewStorage()
-		repo, err = git.Clone(storer, mfs, &git.CloneOptions{
-			URL:   repoURL,
-			Auth:  publicKeys,
-			Depth: 1,
-		})
-		if err != nil {
-			return nil, nil, err
-		}
-		return repo, &mfs, nil
+	publicKeys, err := ssh.NewPublicKeysFromFile("git", gitPrivateKeyFile, gitPrivateKeyFilePassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to use git private key %s: %s", gitPrivateKeyFile, err.Error())
 	}
-
-	return nil, nil, fmt.Errorf("Could not fetch repo")
+	return publicKeys, nil
 }
 
 func Init(ctx context.Context, parentDir, module string) error {
@@ -561,7 +907,7 @@ func Init(ctx context.Context, parentDir, module string) error {
 			"github.com/stakpak/devx-catalog": {
 				V: nil,
 			},
-		}); err != nil {
+		}, nil); err != nil {
 			return err
 		}
 	}
@@ -590,19 +936,24 @@ func Publish(configDir string, stackPath string, buildersPath string, server aut
 		return err
 	}
 
-	value, stackId, depIds := utils.LoadProject(configDir, &overlays)
+	value, stackId, instance := utils.LoadProject(configDir, &overlays, "")
 	if err := ValidateProject(value, stackPath, buildersPath, false); err != nil {
 		return err
 	}
+	if result, err := validateEnvironments(configDir, &overlays, stackPath, buildersPath, false, nil); err != nil {
+		return err
+	} else if len(result) > 0 {
+		return result
+	}
 
 	if stackId == "" {
 		return fmt.Errorf("cannot publish this stack without a module id. please set the \"module\" key to a unique value in \"cue.mod/module.cue\"")
 	}
 
 	project.Stack = stackId
-	project.Imports = depIds
+	project.Imports = instance.Deps
 
-	_, err = stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, depIds)
+	_, err = stack.NewStack(value.LookupPath(cue.ParsePath(stackPath)), stackId, instance.Deps)
 	if err != nil {
 		return err
 	}
@@ -634,18 +985,36 @@ func Publish(configDir string, stackPath string, buildersPath string, server aut
 }
 
 func Import(newPackage string, configDir string, server auth.ServerConfig) error {
-	pkgParts := strings.Split(newPackage, "@")
-	if len(pkgParts) < 2 {
-		return fmt.Errorf("invalid package format, expected \"<git repo>@<git revision>\"")
-	}
-	if len(pkgParts[0]) == 0 {
-		return fmt.Errorf("invalid package format, git repo should not be empty")
-	}
-	if len(pkgParts[1]) == 0 {
-		return fmt.Errorf("invalid package format, git revision should not be empty")
+	var gitRepo string
+	var dep catalog.ModuleDependency
+	var replacement *catalog.ModuleReplacement
+	if isLocalPath(newPackage) {
+		localDir, err := filepath.Abs(newPackage)
+		if err != nil {
+			return err
+		}
+		gitRepo, err = localModulePath(localDir)
+		if err != nil {
+			return err
+		}
+		replacement = &catalog.ModuleReplacement{
+			Path: relativeReplacementPath(configDir, localDir),
+		}
+	} else {
+		pkgParts := strings.Split(newPackage, "@")
+		if len(pkgParts) < 2 {
+			return fmt.Errorf("invalid package format, expected \"<git repo>@<git revision>\" or a local path")
+		}
+		if len(pkgParts[0]) == 0 {
+			return fmt.Errorf("invalid package format, git repo should not be empty")
+		}
+		if len(pkgParts[1]) == 0 {
+			return fmt.Errorf("invalid package format, git revision should not be empty")
+		}
+		gitRepo = pkgParts[0]
+		gitRevision := pkgParts[1]
+		dep.V = &gitRevision
 	}
-	gitRepo := pkgParts[0]
-	gitRevision := pkgParts[1]
 
 	cuemodulePath := path.Join(configDir, "cue.mod", "module.cue")
 	data, err := os.ReadFile(cuemodulePath)
@@ -673,22 +1042,37 @@ func Import(newPackage string, configDir string, server auth.ServerConfig) error
 		}
 	}
 
-	for name := range deps {
-		if strings.HasPrefix(name, gitRepo) {
-			log.Infof("Module %s already exists", gitRepo)
-			return nil
+	replace := map[string]catalog.ModuleReplacement{}
+	replaceValue := cuemodule.LookupPath(cue.ParsePath("replace"))
+	if replaceValue.Exists() {
+		err = replaceValue.Decode(&replace)
+		if err != nil {
+			return err
 		}
 	}
 
-	deps[gitRepo] = catalog.ModuleDependency{
-		V: &gitRevision,
+	if replacement != nil {
+		// a local directory replaces the dependency it was cloned from
+		if _, ok := deps[gitRepo]; !ok {
+			deps[gitRepo] = dep
+		}
+		replace[gitRepo] = *replacement
+		log.Infof("Replacing %s with %s", gitRepo, replacement.Path)
+	} else {
+		for name := range deps {
+			if strings.HasPrefix(name, gitRepo) {
+				log.Infof("Module %s already exists", gitRepo)
+				return nil
+			}
+		}
+		deps[gitRepo] = dep
 	}
 
-	if err := updateModuleFile(configDir, ctx, moduleName, deps); err != nil {
+	if err := updateModuleFile(configDir, ctx, moduleName, deps, replace); err != nil {
 		return err
 	}
 
-	err = Update(configDir, server)
+	err = Update(configDir, server, false)
 	if err != nil {
 		log.Error(err.Error())
 		return errors.New("failed to update packages, fix this issue and re-run devx project update")
@@ -697,12 +1081,33 @@ func Import(newPackage string, configDir string, server auth.ServerConfig) error
 	return nil
 }
 
-func updateModuleFile(configDir string, ctx *cue.Context, module string, deps map[string]catalog.ModuleDependency) error {
+// relativeReplacementPath writes a local directory relative to the project
+// so that module.cue works for everyone with the same checkout layout
+func relativeReplacementPath(configDir string, localDir string) string {
+	absConfigDir, err := filepath.Abs(configDir)
+	if err != nil {
+		return localDir
+	}
+	rel, err := filepath.Rel(absConfigDir, localDir)
+	if err != nil {
+		return localDir
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return rel
+	}
+	return "./" + rel
+}
+
+func updateModuleFile(configDir string, ctx *cue.Context, module string, deps map[string]catalog.ModuleDependency, replace map[string]catalog.ModuleReplacement) error {
 	cuemodulePath := path.Join(configDir, "cue.mod", "module.cue")
 	newcuemodule := ctx.CompileString("")
 	newcuemodule = newcuemodule.FillPath(cue.ParsePath("module"), module)
 	newcuemodule = newcuemodule.FillPath(cue.ParsePath("cue.lang"), "v0.6.0-alpha.1")
 	newcuemodule = newcuemodule.FillPath(cue.ParsePath("deps"), deps)
+	if len(replace) > 0 {
+		newcuemodule = newcuemodule.FillPath(cue.ParsePath("replace"), replace)
+	}
 	bytes, err := format.Node(newcuemodule.Syntax(cue.Concrete(true), cue.Final()), format.Simplify())
 	if err != nil {
 		return err
@@ -713,98 +1118,3 @@ func updateModuleFile(configDir string, ctx *cue.Context, module string, deps ma
 	}
 	return nil
 }
-
-func resolveNestedDependencies(server auth.ServerConfig, deps map[string]catalog.ModuleDependency, depth uint) (map[string]catalog.ModuleDependency, error) {
-	if depth > 10 {
-		return nil, errors.New("exceeded allowed dependency resolution depth")
-	}
-
-	newDeps := map[string]catalog.ModuleDependency{}
-	for name, pkg := range deps {
-		newDeps[name] = pkg
-	}
-
-	for name, pkg := range deps {
-		if !strings.HasPrefix(name, stakpakPrefix) {
-			continue
-		}
-		name = strings.TrimPrefix(name, stakpakPrefix)
-		queryParams := map[string]string{
-			"name": name,
-		}
-
-		if pkg.V != nil {
-			queryParams["version"] = *pkg.V
-		}
-
-		data, err := utils.GetData(
-			server,
-			path.Join("package", "fetch"),
-			nil,
-			queryParams,
-		)
-		if err != nil {
-			return nil, err
-		}
-		packageItem := catalog.ModuleItem{}
-		err = json.Unmarshal(data, &packageItem)
-		if err != nil {
-			return nil, err
-		}
-
-		nestedDeps, err := resolveNestedDependencies(server, packageItem.Dependencies, depth+1)
-		if err != nil {
-			return nil, err
-		}
-
-		for nestedName, nestedPkg := range nestedDeps {
-			if duplicateNestedPkg, ok := newDeps[nestedName]; ok {
-				// if no collision skip and keep existing one
-				if duplicateNestedPkg.V == nestedPkg.V {
-					continue
-				}
-
-				va, vb := "<untagged>", "<untagged>"
-				if duplicateNestedPkg.V != nil {
-					va = *duplicateNestedPkg.V
-				}
-				if nestedPkg.V != nil {
-					vb = *nestedPkg.V
-				}
-				log.Warnf(
-					"possible dependency collision for %s between %s and %s",
-					nestedName, va, vb,
-				)
-
-				// if existing package has no version, replace it by new dependency version
-				if duplicateNestedPkg.V == nil {
-					newDeps[nestedName] = nestedPkg
-					log.Warnf(
-						"using %s",
-						vb,
-					)
-					continue
-				}
-
-				// if new package has no version, keep the old version
-				if nestedPkg.V == nil {
-					log.Warnf(
-						"using %s",
-						va,
-					)
-					continue
-				}
-
-				return nil, fmt.Errorf(
-					"dependency collision for module %s: between %s@%s and %s@%s",
-					packageItem.Module,
-					nestedName, va,
-					nestedName, vb,
-				)
-			}
-			newDeps[nestedName] = nestedPkg
-		}
-	}
-
-	return newDeps, nil
-}
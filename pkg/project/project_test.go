@@ -0,0 +1,21 @@
+package project
+
+import (
+	"testing"
+)
+
+func TestValidateEnvironments(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "cue.mod/module.cue", "module: \"example.com/app\"\n")
+	writeFile(t, dir, "stack.cue", "package main\n\nstack: components: app: replicas: int & >0\n")
+	writeFile(t, dir, "env/dev/stack.cue", "package main\n\nstack: components: app: replicas: 1\n")
+	writeFile(t, dir, "env/prod/stack.cue", "package main\n\nstack: components: app: replicas: 0\n")
+
+	result, err := validateEnvironments(dir, nil, "stack", "builders", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Environment != "prod" {
+		t.Errorf("Expected one diagnostic in prod but found %v", result)
+	}
+}
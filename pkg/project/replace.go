@@ -0,0 +1,124 @@
+package project
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+// replacementString identifies a replacement in the lock, local directories
+// are kept as written in module.cue
+func replacementString(replacement *catalog.ModuleReplacement) string {
+	switch {
+	case replacement == nil:
+		return ""
+	case replacement.Path != "":
+		return replacement.Path
+	case replacement.V != nil:
+		return replacement.Repo + "@" + *replacement.V
+	}
+	return replacement.Repo
+}
+
+// isLocalPath reports whether a package is a local directory rather than a
+// git repo, local directories must start with ./, ../ or /
+func isLocalPath(p string) bool {
+	return p == "." || p == ".." ||
+		strings.HasPrefix(p, "./") ||
+		strings.HasPrefix(p, "../") ||
+		filepath.IsAbs(p)
+}
+
+// validateReplacements checks replacements in module.cue, repos are module
+// paths like github.com/org/repo cloned over https or git URLs
+func validateReplacements(replace map[string]catalog.ModuleReplacement) error {
+	for name, replacement := range replace {
+		switch {
+		case strings.HasPrefix(name, stakpakPrefix):
+			return fmt.Errorf("cannot replace %s, replace is only supported for git dependencies", name)
+		case replacement.Path != "" && replacement.Repo != "":
+			return fmt.Errorf("replacement of %s must set either path or repo", name)
+		case replacement.Path != "" && !isLocalPath(replacement.Path):
+			return fmt.Errorf("replacement path of %s must start with ./, ../ or /", name)
+		case replacement.Path == "" && replacement.Repo == "":
+			return fmt.Errorf("replacement of %s must set a path or a repo", name)
+		case strings.Contains(replacement.Repo, "://") &&
+			!strings.HasPrefix(replacement.Repo, "https://") &&
+			!strings.HasPrefix(replacement.Repo, "ssh://"):
+			return fmt.Errorf("replacement repo of %s must be a module path, an https or an ssh URL", name)
+		}
+	}
+	return nil
+}
+
+// localPackage opens a local replacement, relative paths are relative to the
+// project directory
+func localPackage(configDir string, localPath string) (billy.Filesystem, error) {
+	dir := localPath
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(configDir, filepath.FromSlash(localPath))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cue.mod", "module.cue")); err != nil {
+		return nil, fmt.Errorf("%s is not a module, cue.mod/module.cue not found", localPath)
+	}
+	return osfs.New(dir), nil
+}
+
+// localModulePath is the dependency a local directory replaces, this is the
+// repo of its origin remote or its module name if it has no remote
+func localModulePath(localDir string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(localDir, "cue.mod", "module.cue"))
+	if err != nil {
+		return "", fmt.Errorf("%s is not a module, cue.mod/module.cue not found", localDir)
+	}
+
+	repo, err := git.PlainOpenWithOptions(localDir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err == nil {
+		if remote, err := repo.Remote("origin"); err == nil && len(remote.Config().URLs) > 0 {
+			return modulePathFromURL(remote.Config().URLs[0]), nil
+		}
+	}
+
+	module := cuecontext.New().CompileBytes(data)
+	moduleName, err := module.LookupPath(cue.ParsePath("module")).String()
+	if err != nil || moduleName == "" {
+		return "", fmt.Errorf("%s has no git remote or module name to import it as", localDir)
+	}
+	return moduleName, nil
+}
+
+// gitRepoURL is the URL a git module is cloned from, module paths are cloned
+// over https while https and ssh URLs like git@github.com:org/repo.git are
+// used as they are
+func gitRepoURL(repo string) string {
+	// module paths never contain a scheme or a host separator
+	if strings.Contains(repo, ":") {
+		return repo
+	}
+	return "https://" + repo
+}
+
+// modulePathFromURL converts a git remote URL like https://github.com/org/repo.git
+// or git@github.com:org/repo.git to a module path like github.com/org/repo
+func modulePathFromURL(url string) string {
+	url = strings.TrimSuffix(url, ".git")
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+		if j := strings.Index(url, "@"); j >= 0 && j < strings.Index(url+"/", "/") {
+			url = url[j+1:]
+		}
+		return url
+	}
+	if i := strings.Index(url, "@"); i >= 0 {
+		url = url[i+1:]
+	}
+	return strings.Replace(url, ":", "/", 1)
+}
@@ -0,0 +1,126 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/catalog"
+)
+
+func TestModulePathFromURL(t *testing.T) {
+	for url, expected := range map[string]string{
+		"https://github.com/stakpak/devx-catalog.git":      "github.com/stakpak/devx-catalog",
+		"https://user@github.com/stakpak/devx-catalog":     "github.com/stakpak/devx-catalog",
+		"git@github.com:stakpak/devx-catalog.git":          "github.com/stakpak/devx-catalog",
+		"ssh://git@gitlab.com/group/sub/catalog.git":       "gitlab.com/group/sub/catalog",
+		"https://github.com/stakpak/devx-catalog@main.git": "github.com/stakpak/devx-catalog@main",
+	} {
+		if result := modulePathFromURL(url); result != expected {
+			t.Errorf("Expected %s to convert to %s but found %s", url, expected, result)
+		}
+	}
+}
+
+func TestGitRepoURL(t *testing.T) {
+	for repo, expected := range map[string]string{
+		"github.com/fork/lib":                "https://github.com/fork/lib",
+		"https://github.com/fork/lib.git":    "https://github.com/fork/lib.git",
+		"ssh://git@gitlab.com/group/lib.git": "ssh://git@gitlab.com/group/lib.git",
+		"git@github.com:fork/lib.git":        "git@github.com:fork/lib.git",
+	} {
+		if result := gitRepoURL(repo); result != expected {
+			t.Errorf("Expected %s to be cloned from %s but found %s", repo, expected, result)
+		}
+	}
+}
+
+func TestValidateReplacements(t *testing.T) {
+	for _, replace := range []map[string]catalog.ModuleReplacement{
+		{"stakpak://lib": {Path: "../lib"}},
+		{"github.com/example/lib": {Path: "../lib", Repo: "github.com/fork/lib"}},
+		{"github.com/example/lib": {Path: "lib"}},
+		{"github.com/example/lib": {}},
+		{"github.com/example/lib": {Repo: "file:///tmp/lib"}},
+	} {
+		if err := validateReplacements(replace); err == nil {
+			t.Errorf("Expected %v to be invalid", replace)
+		}
+	}
+
+	v := "feature"
+	if err := validateReplacements(map[string]catalog.ModuleReplacement{
+		"github.com/example/lib":   {Path: "../lib"},
+		"github.com/example/other": {Repo: "github.com/fork/other", V: &v},
+		"github.com/example/https": {Repo: "https://github.com/fork/https.git"},
+		"github.com/example/ssh":   {Repo: "git@github.com:fork/ssh.git", V: &v},
+	}); err != nil {
+		t.Errorf("Expected replacements to be valid but found %s", err)
+	}
+}
+
+func TestImportLocal(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "lib/cue.mod/module.cue", "module: \"example.com/lib\"\n")
+	writeFile(t, dir, "lib/schemas/lib.cue", "package schemas\n\nname: \"lib\"\n")
+	writeFile(t, dir, "app/cue.mod/module.cue", "module: \"example.com/app\"\n")
+
+	configDir := filepath.Join(dir, "app")
+	if err := Import(filepath.Join(dir, "lib"), configDir, auth.ServerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+
+	module, err := os.ReadFile(filepath.Join(configDir, "cue.mod", "module.cue"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(module), `replace: "example.com/lib": path: "../lib"`) {
+		t.Errorf("Expected a relative replacement in module.cue but found\n%s", module)
+	}
+
+	vendored := filepath.Join(configDir, "cue.mod", "pkg", "example.com", "lib", "schemas", "lib.cue")
+	if _, err := os.Stat(vendored); err != nil {
+		t.Errorf("Expected the local module to be vendored: %s", err)
+	}
+
+	lock, err := ReadLock(configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Dependencies["example.com/lib"].Replace != "../lib" {
+		t.Errorf("Expected the replacement to be locked but found %+v", lock.Dependencies)
+	}
+
+	// other dependencies are not fetched again while the lock is verified
+	writeFile(t, configDir, "cue.mod/pkg/example.com/other/other.cue", "package other\n")
+	sum, err := dirSum(configDir, []string{"cue.mod/pkg/example.com/other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := "v1.0.0"
+	lock.Dependencies["github.com/example/other"] = LockedDependency{
+		V:      &version,
+		Commit: "3f1c0de",
+		Sum:    sum,
+		Dirs:   []string{"cue.mod/pkg/example.com/other"},
+	}
+	if err := lock.Write(configDir); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, configDir, "cue.mod/module.cue", string(module)+"\ndeps: \"github.com/example/other\": v: \"v1.0.0\"\n")
+
+	// local replacements are copied again even if the lock is up to date
+	writeFile(t, dir, "lib/schemas/lib.cue", "package schemas\n\nname: \"changed\"\n")
+	if err := EnsureDependencies(configDir, auth.ServerConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(vendored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "changed") {
+		t.Errorf("Expected local changes to be vendored but found\n%s", content)
+	}
+}
@@ -0,0 +1,389 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+	log "github.com/sirupsen/logrus"
+	"github.com/stakpak/devx/pkg/auth"
+	"github.com/stakpak/devx/pkg/catalog"
+	"github.com/stakpak/devx/pkg/utils"
+	"golang.org/x/mod/semver"
+)
+
+// maxResolutionDepth is the longest dependency path allowed from module.cue
+const maxResolutionDepth = 10
+
+// requirement is a version of a module requested by module.cue or by a
+// package along Path
+type requirement struct {
+	V    *string
+	Path []string
+}
+
+func (r requirement) String() string {
+	return fmt.Sprintf("%s requires %s", strings.Join(append([]string{"module.cue"}, r.Path...), " -> "), versionString(r.V))
+}
+
+// resolver selects a version of each module with minimal version selection,
+// the lowest version that satisfies every requirement on a module is used
+type resolver struct {
+	// versions lists the available tags of a module
+	versions func(name string) ([]string, error)
+	// dependencies fetches the dependencies of a stakpak:// package version
+	dependencies func(name string, version *string) (map[string]catalog.ModuleDependency, error)
+	// replace overrides every requirement on a module with its replacement
+	replace map[string]catalog.ModuleReplacement
+
+	versionsCache     map[string][]string
+	dependenciesCache map[string]map[string]catalog.ModuleDependency
+}
+
+func newResolver(server auth.ServerConfig, replace map[string]catalog.ModuleReplacement) *resolver {
+	return &resolver{
+		replace: replace,
+		versions: func(name string) ([]string, error) {
+			if strings.HasPrefix(name, stakpakPrefix) {
+				return listHubVersions(server, strings.TrimPrefix(name, stakpakPrefix))
+			}
+			return listGitTags(gitRepoURL(name))
+		},
+		dependencies: func(name string, version *string) (map[string]catalog.ModuleDependency, error) {
+			packageItem, err := fetchHubPackage(server, strings.TrimPrefix(name, stakpakPrefix), version)
+			if err != nil {
+				return nil, err
+			}
+			return packageItem.Dependencies, nil
+		},
+	}
+}
+
+// resolveDependencies resolves the direct dependencies in module.cue and the
+// dependencies of stakpak:// packages to exact versions, replaced modules
+// resolve to the revision of their replacement
+func resolveDependencies(server auth.ServerConfig, deps map[string]catalog.ModuleDependency, replace map[string]catalog.ModuleReplacement) (map[string]catalog.ModuleDependency, error) {
+	return newResolver(server, replace).resolve(deps)
+}
+
+func (r *resolver) resolve(deps map[string]catalog.ModuleDependency) (map[string]catalog.ModuleDependency, error) {
+	// the requirements of a package depend on the selected version, so
+	// selection is repeated until no new package versions are discovered
+	selected := map[string]catalog.ModuleDependency{}
+	for round := 0; round <= maxResolutionDepth+1; round++ {
+		requirements, err := r.requirements(deps, selected)
+		if err != nil {
+			return nil, err
+		}
+		next, err := r.selectVersions(requirements)
+		if err != nil {
+			return nil, err
+		}
+		if sameSelection(selected, next) {
+			return next, nil
+		}
+		selected = next
+	}
+	return nil, errors.New("exceeded allowed dependency resolution depth")
+}
+
+// requirements walks the dependency graph from module.cue through the
+// selected version of each stakpak:// package
+func (r *resolver) requirements(deps map[string]catalog.ModuleDependency, selected map[string]catalog.ModuleDependency) (map[string][]requirement, error) {
+	type item struct {
+		name string
+		dep  catalog.ModuleDependency
+		path []string
+	}
+
+	result := map[string][]requirement{}
+	visited := map[string]bool{}
+	queue := []item{}
+	for _, name := range sortedNames(deps) {
+		queue = append(queue, item{name, deps[name], []string{}})
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		result[current.name] = append(result[current.name], requirement{V: current.dep.V, Path: current.path})
+
+		pkg, ok := selected[current.name]
+		if visited[current.name] || !ok || !strings.HasPrefix(current.name, stakpakPrefix) {
+			continue
+		}
+		visited[current.name] = true
+		if len(current.path) >= maxResolutionDepth {
+			return nil, errors.New("exceeded allowed dependency resolution depth")
+		}
+
+		nested, err := r.cachedDependencies(current.name, pkg.V)
+		if err != nil {
+			return nil, err
+		}
+		nestedPath := append(append([]string{}, current.path...), current.name+"@"+versionString(pkg.V))
+		for _, name := range sortedNames(nested) {
+			queue = append(queue, item{name, nested[name], nestedPath})
+		}
+	}
+	return result, nil
+}
+
+func (r *resolver) selectVersions(requirements map[string][]requirement) (map[string]catalog.ModuleDependency, error) {
+	result := map[string]catalog.ModuleDependency{}
+	for name, reqs := range requirements {
+		if replacement, ok := r.replace[name]; ok {
+			result[name] = catalog.ModuleDependency{V: replacement.V}
+			continue
+		}
+		version, err := r.selectVersion(name, reqs)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = catalog.ModuleDependency{V: version}
+	}
+	return result, nil
+}
+
+// selectVersion picks the version of a module that satisfies all requirements,
+// an exact revision wins over constraints it satisfies and untagged
+// requirements accept any version, branches and commits never satisfy
+// constraints
+func (r *resolver) selectVersion(name string, reqs []requirement) (*string, error) {
+	var exact *requirement
+	constraints := []*Constraint{}
+	constrained := []requirement{}
+	for i, req := range reqs {
+		switch {
+		case req.V == nil:
+			continue
+		case IsConstraint(*req.V):
+			constraint, err := ParseConstraint(*req.V)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", req, err)
+			}
+			constraints = append(constraints, constraint)
+			constrained = append(constrained, req)
+		case exact == nil:
+			exact = &reqs[i]
+		case *exact.V != *req.V:
+			return nil, collisionError(name, []requirement{*exact, req})
+		}
+	}
+
+	if exact != nil {
+		if _, isVersion := canonicalVersion(*exact.V); !isVersion && len(constraints) > 0 {
+			return nil, fmt.Errorf(
+				"%s\n%s is a branch or commit which cannot satisfy version constraints, require a version tag instead",
+				collisionError(name, append([]requirement{*exact}, constrained...)), *exact.V,
+			)
+		}
+		for i, constraint := range constraints {
+			if !constraint.Check(*exact.V) {
+				return nil, collisionError(name, []requirement{*exact, constrained[i]})
+			}
+		}
+		if len(constraints) == 0 && len(reqs) > 1 {
+			for _, req := range reqs {
+				if req.V == nil {
+					log.Warnf("possible dependency collision for %s between %s and <untagged>, using %s", name, *exact.V, *exact.V)
+					break
+				}
+			}
+		}
+		return exact.V, nil
+	}
+	if len(constraints) == 0 {
+		return nil, nil
+	}
+
+	tags, err := r.cachedVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		matches := true
+		for _, constraint := range constraints {
+			if !constraint.Check(tag) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return &tag, nil
+		}
+	}
+
+	for i, constraint := range constraints {
+		if !anyMatch(constraint, tags) {
+			return nil, fmt.Errorf("no version of %s matches %s\n\t%s", name, constraint, constrained[i])
+		}
+	}
+	return nil, collisionError(name, constrained)
+}
+
+// cachedVersions lists the semantic version tags of a module from lowest to
+// highest
+func (r *resolver) cachedVersions(name string) ([]string, error) {
+	if tags, ok := r.versionsCache[name]; ok {
+		return tags, nil
+	}
+
+	available, err := r.versions(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list versions of %s: %s", name, err)
+	}
+	tags := []string{}
+	for _, tag := range available {
+		if _, ok := canonicalVersion(tag); ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, _ := canonicalVersion(tags[i])
+		b, _ := canonicalVersion(tags[j])
+		return semver.Compare(a, b) < 0
+	})
+
+	if r.versionsCache == nil {
+		r.versionsCache = map[string][]string{}
+	}
+	r.versionsCache[name] = tags
+	return tags, nil
+}
+
+func (r *resolver) cachedDependencies(name string, version *string) (map[string]catalog.ModuleDependency, error) {
+	key := name + "@" + versionString(version)
+	if deps, ok := r.dependenciesCache[key]; ok {
+		return deps, nil
+	}
+
+	deps, err := r.dependencies(name, version)
+	if err != nil {
+		return nil, err
+	}
+	if r.dependenciesCache == nil {
+		r.dependenciesCache = map[string]map[string]catalog.ModuleDependency{}
+	}
+	r.dependenciesCache[key] = deps
+	return deps, nil
+}
+
+func collisionError(name string, reqs []requirement) error {
+	lines := []string{}
+	for _, req := range reqs {
+		lines = append(lines, "\t"+req.String())
+	}
+	return fmt.Errorf("dependency collision for %s:\n%s", name, strings.Join(lines, "\n"))
+}
+
+func anyMatch(constraint *Constraint, tags []string) bool {
+	for _, tag := range tags {
+		if constraint.Check(tag) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameSelection(a map[string]catalog.ModuleDependency, b map[string]catalog.ModuleDependency) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, dep := range a {
+		other, ok := b[name]
+		if !ok || versionString(dep.V) != versionString(other.V) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedNames(deps map[string]catalog.ModuleDependency) []string {
+	names := make([]string, 0, len(deps))
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fetchHubPackage fetches a stakpak:// package, the latest version is fetched
+// if version is nil
+func fetchHubPackage(server auth.ServerConfig, name string, version *string) (catalog.ModuleItem, error) {
+	queryParams := map[string]string{
+		"name": name,
+	}
+	if version != nil {
+		queryParams["version"] = *version
+	}
+
+	data, err := utils.GetData(
+		server,
+		path.Join("package", "fetch"),
+		nil,
+		queryParams,
+	)
+	if err != nil {
+		return catalog.ModuleItem{}, err
+	}
+	packageItem := catalog.ModuleItem{}
+	err = json.Unmarshal(data, &packageItem)
+	return packageItem, err
+}
+
+// listHubVersions lists the published version tags of a stakpak:// package
+func listHubVersions(server auth.ServerConfig, name string) ([]string, error) {
+	data, err := utils.GetData(
+		server,
+		path.Join("package", "versions"),
+		nil,
+		map[string]string{"name": name},
+	)
+	if err != nil {
+		return nil, err
+	}
+	versions := []string{}
+	err = json.Unmarshal(data, &versions)
+	return versions, err
+}
+
+// listGitTags lists the tags of a remote repository without cloning it
+func listGitTags(repoURL string) ([]string, error) {
+	refs, err := listRemoteRefs(repoURL)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := []string{}
+	for _, ref := range refs {
+		if ref.Name().IsTag() {
+			tags = append(tags, ref.Name().Short())
+		}
+	}
+	return tags, nil
+}
+
+func listRemoteRefs(repoURL string) ([]*plumbing.Reference, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoURL},
+	})
+
+	refs, err := remote.List(&git.ListOptions{})
+	if err != nil && err.Error() == "authentication required" {
+		repoAuth, authErr := gitAuth()
+		if authErr != nil {
+			return nil, authErr
+		}
+		refs, err = remote.List(&git.ListOptions{Auth: repoAuth})
+	}
+	return refs, err
+}
@@ -0,0 +1,59 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	log "github.com/sirupsen/logrus"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// AllowCommandsEnv must be set to true to run @guku(cmd) commands
+const AllowCommandsEnv = "DEVX_ALLOW_CMD"
+
+// CommandProvider fills the trimmed output of a shell command run in the
+// project config dir @guku(cmd="git describe --tags"), commands only run if
+// DEVX_ALLOW_CMD is set, never in dry runs and never for fields declared in
+// dependencies under cue.mod/pkg
+type CommandProvider struct{}
+
+func (p *CommandProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	command, err := requireArg(attr, "cmd")
+	if err != nil {
+		return nil, err
+	}
+
+	sourceFiles, _ := ctx.Value(utils.SourceFilesKey).([]string)
+	for _, file := range sourceFiles {
+		if strings.Contains(filepath.ToSlash(file), "/cue.mod/pkg/") {
+			return nil, fmt.Errorf("refusing to run command %q declared in dependency %s", command, file)
+		}
+	}
+	if isDryRun(ctx) {
+		log.Debugf("Skipping command %q in dry run", command)
+		return nil, ErrSkipped
+	}
+	if allowed, _ := strconv.ParseBool(os.Getenv(AllowCommandsEnv)); !allowed {
+		return nil, fmt.Errorf("running commands is disabled, set %s=true to run %q", AllowCommandsEnv, command)
+	}
+
+	log.Debugf("Running command %q", command)
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = getConfigDir(ctx)
+
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("command %q failed: %s %s", command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"cuelang.org/go/cue"
+)
+
+// EnvProvider reads an environment variable @guku(env="NAME")
+type EnvProvider struct{}
+
+func (p *EnvProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *EnvProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	env, err := requireArg(attr, "env")
+	if err != nil {
+		return nil, err
+	}
+	content, found := os.LookupEnv(env)
+	if !found {
+		return nil, fmt.Errorf("environment variable %s not set", env)
+	}
+	return content, nil
+}
@@ -0,0 +1,153 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"cuelang.org/go/cue"
+)
+
+// FileProvider reads a whole file @guku(file="path")
+type FileProvider struct{}
+
+func (p *FileProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *FileProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	filePath, err := requireArg(attr, "file")
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = resolvePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return string(content), nil
+}
+
+// DotEnvProvider reads a key from a dotenv file @guku(dotenv=".env", key="KEY")
+type DotEnvProvider struct{}
+
+func (p *DotEnvProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *DotEnvProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	filePath, err := requireArg(attr, "dotenv")
+	if err != nil {
+		return nil, err
+	}
+	key, err := requireArg(attr, "key")
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = resolvePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	values := parseDotEnv(content)
+	value, ok := values[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s not found in %s", key, filePath)
+	}
+	return value, nil
+}
+
+func parseDotEnv(content []byte) map[string]string {
+	values := map[string]string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(parts[0])
+		value := strings.TrimSpace(parts[1])
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		values[key] = value
+	}
+
+	return values
+}
+
+// JSONProvider reads a dotted key from a JSON file @guku(json="file.json", key="a.b")
+type JSONProvider struct{}
+
+func (p *JSONProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *JSONProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	filePath, err := requireArg(attr, "json")
+	if err != nil {
+		return nil, err
+	}
+	key, err := requireArg(attr, "key")
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = resolvePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+
+	return lookupKey(data, key, filePath)
+}
+
+// lookupKey finds a dotted key in decoded JSON or YAML data
+func lookupKey(data interface{}, key string, source string) (interface{}, error) {
+	current := data
+	for _, part := range strings.Split(key, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in %s", key, source)
+		}
+		current, ok = object[part]
+		if !ok {
+			return nil, fmt.Errorf("key %s not found in %s", key, source)
+		}
+	}
+
+	switch current.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil, fmt.Errorf("key %s in %s is not a scalar value", key, source)
+	}
+	return current, nil
+}
@@ -0,0 +1,123 @@
+package providers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultGeneratedLength = 32
+	dryRunUUID             = "00000000-0000-0000-0000-000000000000"
+)
+
+// dryRunTimestamp is filled in dry runs so that diffs and golden files are
+// stable
+var dryRunTimestamp = time.Unix(0, 0).UTC()
+
+var charsets = map[string]string{
+	"alphanumeric": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"alpha":        "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"numeric":      "0123456789",
+	"hex":          "0123456789abcdef",
+	"symbols":      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#$%&*+-=?@^_~",
+}
+
+// GenerateProvider generates a random string
+// @guku(generate, length=32, charset="alphanumeric"), charset is either one
+// of the named charsets or the literal characters to pick from, dry runs
+// repeat the charset instead
+type GenerateProvider struct{}
+
+func (p *GenerateProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *GenerateProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	length := defaultGeneratedLength
+	if lengthArg, found := lookupArg(attr, "length"); found {
+		parsed, err := strconv.Atoi(lengthArg)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("invalid length %s", lengthArg)
+		}
+		length = parsed
+	}
+
+	charset := charsets["alphanumeric"]
+	if charsetArg, found := lookupArg(attr, "charset"); found {
+		if named, ok := charsets[charsetArg]; ok {
+			charset = named
+		} else if charsetArg != "" {
+			charset = charsetArg
+		}
+	}
+
+	if isDryRun(ctx) {
+		return placeholderString(length, charset), nil
+	}
+	return randomString(length, charset)
+}
+
+func placeholderString(length int, charset string) string {
+	chars := []rune(charset)
+	var result strings.Builder
+	for i := 0; i < length; i++ {
+		result.WriteRune(chars[i%len(chars)])
+	}
+	return result.String()
+}
+
+func randomString(length int, charset string) (string, error) {
+	chars := []rune(charset)
+	max := big.NewInt(int64(len(chars)))
+
+	var result strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		result.WriteRune(chars[n.Int64()])
+	}
+	return result.String(), nil
+}
+
+// UUIDProvider generates a random v4 uuid @guku(uuid), dry runs use the
+// nil uuid
+type UUIDProvider struct{}
+
+func (p *UUIDProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	if isDryRun(ctx) {
+		return dryRunUUID, nil
+	}
+	return uuid.NewString(), nil
+}
+
+// TimestampProvider fills the current time @guku(timestamp, format="RFC3339"),
+// format is unix, RFC3339 (default) or a Go time layout, dry runs use the
+// unix epoch
+type TimestampProvider struct{}
+
+func (p *TimestampProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	now := time.Now().UTC()
+	if isDryRun(ctx) {
+		now = dryRunTimestamp
+	}
+
+	format, _ := lookupArg(attr, "format")
+	switch format {
+	case "", "RFC3339":
+		return now.Format(time.RFC3339), nil
+	case "unix":
+		return now.Unix(), nil
+	default:
+		return now.Format(format), nil
+	}
+}
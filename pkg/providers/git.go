@@ -0,0 +1,51 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/gitrepo"
+)
+
+// GitProvider fills data about the project's current git commit
+// @guku(git="commit"), one of commit | short | branch | message | author | tag
+type GitProvider struct{}
+
+func (p *GitProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	field, err := requireArg(attr, "git")
+	if err != nil {
+		return nil, err
+	}
+
+	gitData, err := gitrepo.GetGitData(getConfigDir(ctx))
+	if err != nil {
+		return nil, err
+	}
+	if gitData == nil {
+		return nil, fmt.Errorf("git is not initialized")
+	}
+
+	switch field {
+	case "commit":
+		return gitData.Commit, nil
+	case "short":
+		if len(gitData.Commit) > 7 {
+			return gitData.Commit[:7], nil
+		}
+		return gitData.Commit, nil
+	case "branch":
+		return gitData.Branch, nil
+	case "message":
+		return gitData.Message, nil
+	case "author":
+		return gitData.Author, nil
+	case "tag":
+		if len(gitData.Tags) == 0 {
+			return nil, fmt.Errorf("no semver tags found on commit %s", gitData.Commit)
+		}
+		return gitData.Tags[0], nil
+	}
+
+	return nil, fmt.Errorf("unknown git field %s, expected commit | short | branch | message | author | tag", field)
+}
@@ -0,0 +1,118 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+// Provider resolves the value of a non-concrete field annotated with
+// @guku(<key>...), the whole attribute is passed to allow extra arguments
+type Provider interface {
+	Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error)
+}
+
+// SensitiveProvider is implemented by providers resolving values that could
+// be secrets, these values are masked in logs, stdout, diffs and uploads
+type SensitiveProvider interface {
+	IsSensitive() bool
+}
+
+// ErrSkipped is returned by providers that do not run in dry runs, the
+// field is filled with a placeholder instead
+var ErrSkipped = errors.New("skipped in dry run")
+
+type registration struct {
+	key      string
+	provider Provider
+}
+
+// providers are matched in registration order, the first key found in an
+// attribute wins
+var registry = []registration{
+	{"file", &FileProvider{}},
+	{"env", &EnvProvider{}},
+	{"generate", &GenerateProvider{}},
+	{"uuid", &UUIDProvider{}},
+	{"timestamp", &TimestampProvider{}},
+	{"git", &GitProvider{}},
+	{"cmd", &CommandProvider{}},
+	{"dotenv", &DotEnvProvider{}},
+	{"json", &JSONProvider{}},
+	{"sops", &SOPSProvider{}},
+	{"age", &AgeProvider{}},
+}
+
+// Register adds a provider for @guku(<key>) attributes or replaces the
+// provider already registered for key
+func Register(key string, provider Provider) {
+	for i, r := range registry {
+		if r.key == key {
+			registry[i].provider = provider
+			return
+		}
+	}
+	registry = append(registry, registration{key, provider})
+}
+
+// Lookup returns the first registered provider referenced by attr either as
+// a flag @guku(key) or as a key value pair @guku(key=value)
+func Lookup(attr cue.Attribute) (string, Provider, bool) {
+	for _, r := range registry {
+		if isFlag, _ := attr.Flag(0, r.key); isFlag {
+			return r.key, r.provider, true
+		}
+		if _, found, _ := attr.Lookup(0, r.key); found {
+			return r.key, r.provider, true
+		}
+	}
+	return "", nil, false
+}
+
+func lookupArg(attr cue.Attribute, key string) (string, bool) {
+	value, found, _ := attr.Lookup(0, key)
+	return value, found
+}
+
+func requireArg(attr cue.Attribute, key string) (string, error) {
+	value, found := lookupArg(attr, key)
+	if !found {
+		return "", fmt.Errorf("missing %s argument in %s", key, attr)
+	}
+	return value, nil
+}
+
+func getConfigDir(ctx context.Context) string {
+	configDir, ok := ctx.Value(utils.ConfigDirKey).(string)
+	if !ok {
+		return "."
+	}
+	return configDir
+}
+
+func isDryRun(ctx context.Context) bool {
+	dryRun, _ := ctx.Value(utils.DryRunKey).(bool)
+	return dryRun
+}
+
+// resolvePath resolves paths relative to the project config dir
+func resolvePath(ctx context.Context, filePath string) (string, error) {
+	if !strings.HasPrefix(filePath, "/") {
+		filePath = filepath.Join(getConfigDir(ctx), filePath)
+	}
+	return verifyPath(filePath)
+}
+
+func verifyPath(path string) (string, error) {
+	c := filepath.Clean(path)
+	r, err := filepath.EvalSymlinks(c)
+	if err != nil {
+		return c, fmt.Errorf("unsafe or invalid path specified: %s", err)
+	}
+	return r, nil
+}
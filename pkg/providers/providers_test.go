@@ -0,0 +1,144 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+func resolveField(t *testing.T, ctx context.Context, field string) (string, interface{}, error) {
+	value := cuecontext.New().CompileString("x: string " + field)
+	attr := value.LookupPath(cue.ParsePath("x")).Attribute("guku")
+	key, provider, found := Lookup(attr)
+	if !found {
+		t.Fatalf("Expected a provider for %s", field)
+	}
+	result, err := provider.Resolve(ctx, attr)
+	return key, result, err
+}
+
+func TestLookupOrder(t *testing.T) {
+	t.Setenv("DEVX_TEST_VALUE", "from-env")
+
+	key, result, err := resolveField(t, context.Background(), `@guku(generate,env="DEVX_TEST_VALUE")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != "env" || result != "from-env" {
+		t.Errorf("Expected env provider to take precedence but got %s=%v", key, result)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	_, result, err := resolveField(t, context.Background(), `@guku(generate,length=12,charset="numeric")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := result.(string)
+	if len(value) != 12 {
+		t.Errorf("Expected 12 characters but found %d in %s", len(value), value)
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			t.Errorf("Expected only numeric characters but found %s", value)
+		}
+	}
+
+	_, result, err = resolveField(t, context.Background(), `@guku(generate)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.(string)) != defaultGeneratedLength {
+		t.Errorf("Expected %d characters but found %s", defaultGeneratedLength, result)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	ctx := context.WithValue(context.Background(), utils.DryRunKey, true)
+
+	for field, expected := range map[string]interface{}{
+		`@guku(generate,length=5,charset="hex")`: "01234",
+		`@guku(uuid)`:                            dryRunUUID,
+		`@guku(timestamp)`:                       "1970-01-01T00:00:00Z",
+		`@guku(timestamp,format="unix")`:         int64(0),
+	} {
+		_, result, err := resolveField(t, ctx, field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Errorf("Expected %s to resolve to %v but found %v", field, expected, result)
+		}
+	}
+
+	t.Setenv(AllowCommandsEnv, "true")
+	if _, _, err := resolveField(t, ctx, `@guku(cmd="echo hello")`); err != ErrSkipped {
+		t.Errorf("Expected commands to be skipped in dry runs but found %v", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	ctx := context.WithValue(context.Background(), utils.ConfigDirKey, t.TempDir())
+
+	if _, _, err := resolveField(t, ctx, `@guku(cmd="echo hello")`); err == nil {
+		t.Error("Expected commands to require an opt-in")
+	}
+
+	t.Setenv(AllowCommandsEnv, "true")
+	_, result, err := resolveField(t, ctx, `@guku(cmd="echo hello")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "hello" {
+		t.Errorf("Expected \"hello\" but found %v", result)
+	}
+
+	vendored := context.WithValue(ctx, utils.SourceFilesKey, []string{"/app/main.cue", "/app/cue.mod/pkg/example.com/lib/lib.cue"})
+	if _, _, err := resolveField(t, vendored, `@guku(cmd="echo hello")`); err == nil {
+		t.Error("Expected commands declared in dependencies to be refused")
+	}
+}
+
+func TestFileKeys(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.WithValue(context.Background(), utils.ConfigDirKey, dir)
+
+	dotenv := "# comment\nexport API_KEY=\"abc 123\"\nOTHER=value # trailing\n"
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte(dotenv), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, result, err := resolveField(t, ctx, `@guku(dotenv=".env",key="API_KEY")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "abc 123" {
+		t.Errorf("Expected \"abc 123\" but found %v", result)
+	}
+	_, result, err = resolveField(t, ctx, `@guku(dotenv=".env",key="OTHER")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "value" {
+		t.Errorf("Expected \"value\" but found %v", result)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"db": {"password": "secret"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, result, err = resolveField(t, ctx, `@guku(json="config.json",key="db.password")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "secret" {
+		t.Errorf("Expected \"secret\" but found %v", result)
+	}
+	_, _, err = resolveField(t, ctx, `@guku(json="config.json",key="db.user")`)
+	if err == nil {
+		t.Error("Expected missing key error")
+	}
+}
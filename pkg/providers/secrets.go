@@ -0,0 +1,240 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"gopkg.in/yaml.v3"
+)
+
+// SOPSProvider reads a key from a SOPS encrypted YAML or JSON file
+// @guku(sops="secrets.enc.yaml", key="db.password"), only age keys are
+// supported and the file MAC is not verified
+type SOPSProvider struct{}
+
+func (p *SOPSProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *SOPSProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	filePath, err := requireArg(attr, "sops")
+	if err != nil {
+		return nil, err
+	}
+	key, err := requireArg(attr, "key")
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = resolvePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := yaml.Unmarshal(content, &data); err != nil {
+		return nil, err
+	}
+
+	metadata, ok := data["sops"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s is not a sops encrypted file", filePath)
+	}
+
+	dataKey, err := decryptSOPSDataKey(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt sops data key of %s: %s", filePath, err)
+	}
+
+	delete(data, "sops")
+	value, err := lookupKey(data, key, filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, ok := value.(string)
+	if !ok || !strings.HasPrefix(encrypted, "ENC[") {
+		// unencrypted values are returned as is
+		return value, nil
+	}
+
+	return decryptSOPSValue(dataKey, encrypted, strings.Split(key, "."))
+}
+
+func decryptSOPSDataKey(metadata map[string]interface{}) ([]byte, error) {
+	identities, err := getAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients, ok := metadata["age"].([]interface{})
+	if !ok || len(recipients) == 0 {
+		return nil, fmt.Errorf("no age recipients found")
+	}
+
+	for _, recipient := range recipients {
+		recipientMap, ok := recipient.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		enc, ok := recipientMap["enc"].(string)
+		if !ok {
+			continue
+		}
+		dataKey, err := ageDecrypt([]byte(enc), identities)
+		if err == nil {
+			return dataKey, nil
+		}
+	}
+
+	return nil, fmt.Errorf("none of the age identities match the file recipients")
+}
+
+var sopsValueRegex = regexp.MustCompile(`^ENC\[AES256_GCM,data:(.*),iv:(.*),tag:(.*),type:(.*)\]$`)
+
+func decryptSOPSValue(dataKey []byte, encrypted string, path []string) (interface{}, error) {
+	matches := sopsValueRegex.FindStringSubmatch(encrypted)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid sops encrypted value")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(matches[1])
+	if err != nil {
+		return nil, err
+	}
+	iv, err := base64.StdEncoding.DecodeString(matches[2])
+	if err != nil {
+		return nil, err
+	}
+	tag, err := base64.StdEncoding.DecodeString(matches[3])
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(iv))
+	if err != nil {
+		return nil, err
+	}
+
+	// sops authenticates each value with the path of keys leading to it
+	additionalData := []byte(strings.Join(path, ":") + ":")
+	plaintext, err := gcm.Open(nil, iv, append(data, tag...), additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt value: %s", err)
+	}
+
+	switch valueType := matches[4]; valueType {
+	case "str", "bytes":
+		return string(plaintext), nil
+	case "int":
+		return strconv.ParseInt(string(plaintext), 10, 64)
+	case "float":
+		return strconv.ParseFloat(string(plaintext), 64)
+	case "bool":
+		return strconv.ParseBool(string(plaintext))
+	default:
+		return nil, fmt.Errorf("unsupported sops value type %s", valueType)
+	}
+}
+
+// AgeProvider decrypts an age encrypted file @guku(age="secret.age"), if a
+// key is specified the decrypted content is parsed as YAML or JSON
+// @guku(age="secrets.yaml.age", key="db.password")
+type AgeProvider struct{}
+
+func (p *AgeProvider) IsSensitive() bool {
+	return true
+}
+
+func (p *AgeProvider) Resolve(ctx context.Context, attr cue.Attribute) (interface{}, error) {
+	filePath, err := requireArg(attr, "age")
+	if err != nil {
+		return nil, err
+	}
+	filePath, err = resolvePath(ctx, filePath)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	identities, err := getAgeIdentities()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := ageDecrypt(content, identities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %s", filePath, err)
+	}
+
+	key, found := lookupArg(attr, "key")
+	if !found {
+		return string(plaintext), nil
+	}
+
+	var data interface{}
+	if err := yaml.Unmarshal(plaintext, &data); err != nil {
+		return nil, err
+	}
+	return lookupKey(data, key, filePath)
+}
+
+func ageDecrypt(content []byte, identities []age.Identity) ([]byte, error) {
+	var reader io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte(armor.Header)) {
+		reader = armor.NewReader(bytes.NewReader(bytes.TrimSpace(content)))
+	}
+
+	decrypted, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decrypted)
+}
+
+// getAgeIdentities reads age identities following sops conventions, from
+// SOPS_AGE_KEY, SOPS_AGE_KEY_FILE or the default sops keys file
+func getAgeIdentities() ([]age.Identity, error) {
+	if key, ok := os.LookupEnv("SOPS_AGE_KEY"); ok {
+		return age.ParseIdentities(strings.NewReader(key))
+	}
+
+	keyFile, ok := os.LookupEnv("SOPS_AGE_KEY_FILE")
+	if !ok {
+		configDir, err := os.UserConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		keyFile = filepath.Join(configDir, "sops", "age", "keys.txt")
+	}
+
+	file, err := os.Open(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("no age key found, set SOPS_AGE_KEY or SOPS_AGE_KEY_FILE: %s", err)
+	}
+	defer file.Close()
+
+	return age.ParseIdentities(file)
+}
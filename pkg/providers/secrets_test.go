@@ -0,0 +1,116 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+func ageEncrypt(t *testing.T, recipient age.Recipient, plaintext []byte) string {
+	out := new(bytes.Buffer)
+	armorWriter := armor.NewWriter(out)
+	w, err := age.Encrypt(armorWriter, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	armorWriter.Close()
+	return out.String()
+}
+
+func sopsEncrypt(t *testing.T, dataKey []byte, value string, path []string) string {
+	block, _ := aes.NewCipher(dataKey)
+	iv := make([]byte, 32)
+	rand.Read(iv)
+	gcm, _ := cipher.NewGCMWithNonceSize(block, len(iv))
+	sealed := gcm.Seal(nil, iv, []byte(value), []byte(strings.Join(path, ":")+":"))
+	data, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+	return fmt.Sprintf(
+		"ENC[AES256_GCM,data:%s,iv:%s,tag:%s,type:str]",
+		base64.StdEncoding.EncodeToString(data),
+		base64.StdEncoding.EncodeToString(iv),
+		base64.StdEncoding.EncodeToString(tag),
+	)
+}
+
+func TestSecrets(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+
+	dir := t.TempDir()
+	ctx := context.WithValue(context.Background(), utils.ConfigDirKey, dir)
+
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	encryptedDataKey := ageEncrypt(t, identity.Recipient(), dataKey)
+
+	sopsFile := fmt.Sprintf(`db:
+    password: %s
+    user: admin
+sops:
+    age:
+        - recipient: %s
+          enc: |
+%s
+`,
+		sopsEncrypt(t, dataKey, "s3cr3t", []string{"db", "password"}),
+		identity.Recipient().String(),
+		"            "+strings.ReplaceAll(strings.TrimSpace(encryptedDataKey), "\n", "\n            "),
+	)
+	if err := os.WriteFile(filepath.Join(dir, "secrets.enc.yaml"), []byte(sopsFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, result, err := resolveField(t, ctx, `@guku(sops="secrets.enc.yaml",key="db.password")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "s3cr3t" {
+		t.Errorf("Expected \"s3cr3t\" but found %v", result)
+	}
+
+	_, result, err = resolveField(t, ctx, `@guku(sops="secrets.enc.yaml",key="db.user")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "admin" {
+		t.Errorf("Expected \"admin\" but found %v", result)
+	}
+
+	ageFile := ageEncrypt(t, identity.Recipient(), []byte("token: abc\n"))
+	if err := os.WriteFile(filepath.Join(dir, "secrets.yaml.age"), []byte(ageFile), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, result, err = resolveField(t, ctx, `@guku(age="secrets.yaml.age",key="token")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "abc" {
+		t.Errorf("Expected \"abc\" but found %v", result)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	t.Setenv("SOPS_AGE_KEY", other.String())
+	_, _, err = resolveField(t, ctx, `@guku(sops="secrets.enc.yaml",key="db.password")`)
+	if err == nil {
+		t.Error("Expected decryption to fail with a different identity")
+	}
+}
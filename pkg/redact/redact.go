@@ -0,0 +1,186 @@
+package redact
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	log "github.com/sirupsen/logrus"
+)
+
+const Mask = "*****"
+
+var (
+	mu      sync.RWMutex
+	secrets = map[string]bool{}
+	paths   = map[string]bool{}
+)
+
+// minLength is the shortest value masked by content, shorter values are only
+// masked by their registered path
+const minLength = 6
+
+// Add registers a sensitive value to be masked wherever data leaves the
+// process. Short values, booleans and numbers are too likely to appear in
+// unrelated output and are only masked by path
+func Add(secret string) {
+	if !isMaskable(secret) {
+		return
+	}
+	addValue(secret)
+}
+
+// AddSensitive registers a value resolved from an explicitly sensitive
+// source like @guku(secret) or a sensitive provider, unlike Add short values
+// and numbers are masked too, only empty values and booleans are skipped
+func AddSensitive(secret string) {
+	if secret == "" {
+		return
+	}
+	if _, err := strconv.ParseBool(secret); err == nil {
+		return
+	}
+	addValue(secret)
+}
+
+func addValue(secret string) {
+	mu.Lock()
+	defer mu.Unlock()
+	secrets[secret] = true
+
+	// values are also masked in their json escaped form for uploads
+	if escaped, err := json.Marshal(secret); err == nil {
+		escapedSecret := strings.Trim(string(escaped), "\"")
+		if escapedSecret != secret {
+			secrets[escapedSecret] = true
+		}
+	}
+}
+
+// AddPath registers a sensitive component path, e.g. app.containers.default.env.API_KEY
+func AddPath(path string) {
+	mu.Lock()
+	defer mu.Unlock()
+	paths[path] = true
+}
+
+// IsSensitivePath reports whether path or one of its parents was registered
+// as sensitive
+func IsSensitivePath(path string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for sensitivePath := range paths {
+		if path == sensitivePath ||
+			strings.HasPrefix(path, sensitivePath+".") ||
+			strings.HasPrefix(path, sensitivePath+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+func isMaskable(secret string) bool {
+	if len(secret) < minLength {
+		return false
+	}
+	if _, err := strconv.ParseBool(secret); err == nil {
+		return false
+	}
+	if _, err := strconv.ParseFloat(secret, 64); err == nil {
+		return false
+	}
+	return true
+}
+
+// Tree masks the values at registered sensitive paths in data decoded from
+// json and registered sensitive values in its strings, path is the path of
+// data itself and empty for the stack components
+func Tree(data interface{}, path string) interface{} {
+	if path != "" && IsSensitivePath(path) {
+		return Mask
+	}
+
+	switch value := data.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, field := range value {
+			label := cue.MakePath(cue.Str(key)).String()
+			if path != "" {
+				label = path + "." + label
+			}
+			result[key] = Tree(field, label)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(value))
+		for i, element := range value {
+			result[i] = Tree(element, path+"["+strconv.Itoa(i)+"]")
+		}
+		return result
+	case string:
+		return String(value)
+	}
+	return data
+}
+
+// String masks all registered sensitive values in s
+func String(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if len(secrets) == 0 {
+		return s
+	}
+
+	// replace longer values first so that overlapping secrets are fully masked
+	values := make([]string, 0, len(secrets))
+	for secret := range secrets {
+		values = append(values, secret)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return len(values[i]) > len(values[j])
+	})
+
+	for _, secret := range values {
+		s = strings.ReplaceAll(s, secret, Mask)
+	}
+	return s
+}
+
+func Bytes(b []byte) []byte {
+	return []byte(String(string(b)))
+}
+
+// Writer masks sensitive values in everything written to W
+type Writer struct {
+	W io.Writer
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if _, err := w.W.Write(Bytes(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// LogHook masks sensitive values in log messages and fields
+type LogHook struct{}
+
+func (h *LogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *LogHook) Fire(entry *log.Entry) error {
+	entry.Message = String(entry.Message)
+	for key, value := range entry.Data {
+		if s, ok := value.(string); ok {
+			entry.Data[key] = String(s)
+		}
+	}
+	return nil
+}
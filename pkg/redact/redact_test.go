@@ -0,0 +1,92 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	Add("p4ss\"word")
+	Add("p4ssw0rd")
+	for _, trivial := range []string{"1", "true", "8080", "db", "3.14159"} {
+		Add(trivial)
+	}
+
+	masked := String(`{"password":"p4ss\"word","other":"p4ssw0rd","port":8080,"enabled":true,"db":"db"}`)
+	expected := `{"password":"*****","other":"*****","port":8080,"enabled":true,"db":"db"}`
+	if masked != expected {
+		t.Errorf("Expected %s but found %s", expected, masked)
+	}
+}
+
+func TestAddSensitive(t *testing.T) {
+	AddSensitive("4821")
+	AddSensitive("false")
+
+	masked := String(`pin: 4821, enabled: false`)
+	expected := `pin: *****, enabled: false`
+	if masked != expected {
+		t.Errorf("Expected %s but found %s", expected, masked)
+	}
+}
+
+func TestIsSensitivePath(t *testing.T) {
+	AddPath("app.env.API_KEY")
+	AddPath("db.credentials")
+
+	for path, expected := range map[string]bool{
+		"app.env.API_KEY":      true,
+		"db.credentials.user":  true,
+		"db.credentials[0]":    true,
+		"app.env.API_KEY_NAME": false,
+		"app.env":              false,
+	} {
+		if IsSensitivePath(path) != expected {
+			t.Errorf("Expected IsSensitivePath(%s) to be %v", path, expected)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	AddPath("api.env.TOKEN")
+	AddPath(`"my-db".password`)
+
+	data := map[string]interface{}{
+		"api": map[string]interface{}{
+			"env":   map[string]interface{}{"TOKEN": "1", "PORT": "1"},
+			"ports": []interface{}{map[string]interface{}{"port": 1.0}},
+		},
+		"my-db": map[string]interface{}{"password": "true", "user": "true"},
+	}
+	masked := Tree(data, "").(map[string]interface{})
+
+	api := masked["api"].(map[string]interface{})
+	env := api["env"].(map[string]interface{})
+	if env["TOKEN"] != Mask || env["PORT"] != "1" {
+		t.Errorf("Expected only api.env.TOKEN to be masked but found %v", env)
+	}
+	db := masked["my-db"].(map[string]interface{})
+	if db["password"] != Mask || db["user"] != "true" {
+		t.Errorf("Expected only \"my-db\".password to be masked but found %v", db)
+	}
+	if data["api"].(map[string]interface{})["env"].(map[string]interface{})["TOKEN"] != "1" {
+		t.Errorf("Expected the input to be left unchanged")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	Add("s3cr3t-t0k3n")
+
+	out := strings.Builder{}
+	w := Writer{W: &out}
+	n, err := w.Write([]byte("token: s3cr3t-t0k3n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len("token: s3cr3t-t0k3n\n") {
+		t.Errorf("Expected written length to match input but found %d", n)
+	}
+	if out.String() != "token: *****\n" {
+		t.Errorf("Expected masked output but found %s", out.String())
+	}
+}
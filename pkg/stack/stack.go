@@ -9,11 +9,11 @@ import (
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/ast"
 	"cuelang.org/go/cue/ast/astutil"
-	"cuelang.org/go/cue/cuecontext"
 	cueflow "cuelang.org/go/tools/flow"
 	log "github.com/sirupsen/logrus"
 	"github.com/stakpak/devx/pkg/auth"
 	"github.com/stakpak/devx/pkg/gitrepo"
+	"github.com/stakpak/devx/pkg/redact"
 	"github.com/stakpak/devx/pkg/utils"
 )
 
@@ -39,30 +39,10 @@ func NewStack(value cue.Value, stackId string, depIds []string) (*Stack, error)
 		dependencies: make(map[string][]string),
 	}
 
-	cfg := &cueflow.Config{
-		FindHiddenTasks: true,
-		Root:            cue.ParsePath(""),
+	if err := stack.computeDependencies(); err != nil {
+		return nil, err
 	}
 
-	flow := cueflow.New(
-		cfg,
-		components,
-		taskFunc,
-	)
-
-	tasks := flow.Tasks()
-	for _, task := range tasks {
-		id := utils.GetLastPathFragment(task.Value())
-		parents := task.Dependencies()
-		stack.dependencies[id] = make([]string, 0)
-		for _, parent := range parents {
-			parentId := utils.GetLastPathFragment(parent.Value())
-			stack.addDependency(id, parentId)
-		}
-	}
-
-	stack.tasks = computeOrderedTasks(stack)
-
 	return stack, nil
 }
 
@@ -124,9 +104,18 @@ func (s *Stack) GetTasks() []string {
 	return s.tasks
 }
 
-func (s *Stack) AddComponents(value cue.Value) {
+func (s *Stack) AddComponents(value cue.Value) error {
 	s.components = s.components.FillPath(cue.ParsePath(""), value)
+	if s.components.Err() != nil {
+		return s.components.Err()
+	}
+
+	return s.computeDependencies()
+}
 
+// computeDependencies infers dependencies from references between components,
+// merges explicit $metadata.dependsOn declarations and orders tasks accordingly
+func (s *Stack) computeDependencies() error {
 	cfg := &cueflow.Config{
 		FindHiddenTasks: true,
 		Root:            cue.ParsePath(""),
@@ -138,6 +127,9 @@ func (s *Stack) AddComponents(value cue.Value) {
 		taskFunc,
 	)
 
+	s.dependencies = make(map[string][]string)
+	explicitDependencies := map[string][]string{}
+
 	tasks := flow.Tasks()
 	for _, task := range tasks {
 		id := utils.GetLastPathFragment(task.Value())
@@ -147,16 +139,105 @@ func (s *Stack) AddComponents(value cue.Value) {
 			parentId := utils.GetLastPathFragment(parent.Value())
 			s.addDependency(id, parentId)
 		}
+
+		dependsOnValue := task.Value().LookupPath(cue.ParsePath("$metadata.dependsOn"))
+		if dependsOnValue.Exists() {
+			dependsOn := []string{}
+			if err := dependsOnValue.Decode(&dependsOn); err != nil {
+				return fmt.Errorf("invalid $metadata.dependsOn in component %s: %s", id, err)
+			}
+			explicitDependencies[id] = dependsOn
+		}
+	}
+
+	// sorted so that the first unknown component reported is always the same
+	explicitIds := make([]string, 0, len(explicitDependencies))
+	for id := range explicitDependencies {
+		explicitIds = append(explicitIds, id)
+	}
+	sort.Strings(explicitIds)
+	for _, id := range explicitIds {
+		for _, depId := range explicitDependencies[id] {
+			if _, ok := s.dependencies[depId]; !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", id, depId)
+			}
+			s.addDependency(id, depId)
+		}
+	}
+
+	if cycle := findCycle(s); cycle != nil {
+		return fmt.Errorf("dependency cycle detected between components: %s", strings.Join(cycle, " -> "))
 	}
 
 	s.tasks = computeOrderedTasks(s)
+
+	return nil
 }
 
 func (s *Stack) addDependency(id string, depId string) {
+	for _, existing := range s.dependencies[id] {
+		if existing == depId {
+			return
+		}
+	}
 	s.dependencies[id] = append(s.dependencies[id], depId)
 }
 
-// cue flow already checks for cycles, so we don't have to
+// findCycle returns the components forming a dependency cycle, if any.
+// cue flow already checks reference cycles, but explicit dependsOn
+// declarations can still introduce them
+func findCycle(s *Stack) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+
+	ids := make([]string, 0, len(s.dependencies))
+	for id := range s.dependencies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	state := make(map[string]int)
+	path := make([]string, 0)
+
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		state[id] = visiting
+		path = append(path, id)
+		for _, parent := range s.dependencies[id] {
+			switch state[parent] {
+			case visiting:
+				for i, p := range path {
+					if p == parent {
+						cycle := append([]string{}, path[i:]...)
+						return append(cycle, parent)
+					}
+				}
+			case unvisited:
+				if cycle := visit(parent); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+
+	for _, id := range ids {
+		if state[id] == unvisited {
+			if cycle := visit(id); cycle != nil {
+				return cycle
+			}
+		}
+	}
+
+	return nil
+}
+
+// cycles are rejected by findCycle before ordering
 func computeOrderedTasks(s *Stack) []string {
 
 	result := make([]string, 0)
@@ -219,7 +300,7 @@ func (s *Stack) GetComponents() cue.Value {
 type BuildData struct {
 	Stack       string                 `json:"stack"`
 	Identity    string                 `json:"identity,omitempty"`
-	Result      cue.Value              `json:"result"`
+	Result      interface{}            `json:"result"`
 	Imports     []string               `json:"imports"`
 	References  map[string][]Reference `json:"references"`
 	Environment string                 `json:"environment"`
@@ -233,22 +314,9 @@ type Reference struct {
 }
 
 func (s *Stack) SendBuild(configDir string, server auth.ServerConfig, environment string, buildError *string) (string, error) {
-	build := BuildData{
-		Stack:       s.ID,
-		Identity:    "",
-		Imports:     s.DepIDs,
-		Environment: environment,
-		Git:         nil,
-		Error:       buildError,
-		Source:      s.BuildSource,
-	}
-
-	if buildError == nil {
-		build.Result = s.GetComponents()
-		build.References = s.GetReferences()
-	} else {
-		build.Result = cuecontext.New().CompileString("{}")
-		build.References = map[string][]Reference{}
+	build, err := s.newBuildData(environment, buildError)
+	if err != nil {
+		return "", err
 	}
 
 	gitData, err := gitrepo.GetGitData(configDir)
@@ -257,7 +325,7 @@ func (s *Stack) SendBuild(configDir string, server auth.ServerConfig, environmen
 	}
 	build.Git = gitData
 
-	data, err := utils.SendData(server, "builds", &build)
+	data, err := utils.SendData(server, "builds", build)
 	if err != nil {
 		return "", err
 	}
@@ -271,6 +339,35 @@ func (s *Stack) SendBuild(configDir string, server auth.ServerConfig, environmen
 	return buildResponse["id"], nil
 }
 
+// newBuildData builds the uploaded build with all sensitive values masked,
+// components are masked by path and everything else by value
+func (s *Stack) newBuildData(environment string, buildError *string) (*BuildData, error) {
+	build := BuildData{
+		Stack:       s.ID,
+		Identity:    "",
+		Imports:     s.DepIDs,
+		Environment: environment,
+		Git:         nil,
+		Source:      redact.String(s.BuildSource),
+	}
+
+	if buildError != nil {
+		redactedError := redact.String(*buildError)
+		build.Error = &redactedError
+		build.Result = map[string]interface{}{}
+		build.References = map[string][]Reference{}
+		return &build, nil
+	}
+
+	result, err := redactedComponents(s.GetComponents())
+	if err != nil {
+		return nil, err
+	}
+	build.Result = result
+	build.References = s.GetReferences()
+	return &build, nil
+}
+
 func (s *Stack) GetReferences() map[string][]Reference {
 	refMap := map[string][]Reference{}
 
@@ -384,3 +481,27 @@ func removeDuplicates(refs []Reference) []Reference {
 	}
 	return list
 }
+
+// RedactedComponents returns the components with the values at sensitive
+// paths and all registered sensitive values masked
+func (s *Stack) RedactedComponents() (cue.Value, error) {
+	result, err := redactedComponents(s.components)
+	if err != nil {
+		return cue.Value{}, err
+	}
+	return s.components.Context().Encode(result), nil
+}
+
+// redactedComponents decodes components masking the values at sensitive
+// paths so that secrets are never uploaded
+func redactedComponents(components cue.Value) (interface{}, error) {
+	data, err := components.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return redact.Tree(result, ""), nil
+}
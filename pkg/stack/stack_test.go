@@ -1,9 +1,12 @@
 package stack
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/redact"
 )
 
 var stackString1 = `
@@ -97,3 +100,143 @@ func TestTaskOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestDependsOn(t *testing.T) {
+	stackString := `
+	components: {
+		migrate: {
+			$metadata: id: "migrate"
+		}
+		app: {
+			$metadata: {
+				id: "app"
+				dependsOn: ["migrate"]
+			}
+			todo: db.todo
+		}
+		db: {
+			$metadata: {
+				id: "db"
+				dependsOn: ["migrate"]
+			}
+			todo: 123
+		}
+	}
+	`
+	ctx := cuecontext.New()
+	value := ctx.CompileString(stackString)
+
+	stack, err := NewStack(value, "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dependencies, err := stack.GetDependencies("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dependencies) != 2 || dependencies[0] != "db" || dependencies[1] != "migrate" {
+		t.Errorf("Expected app dependencies [db migrate] but found %s", dependencies)
+	}
+
+	taskOrder := stack.GetTasks()
+	expectedOrder := []string{"migrate", "db", "app"}
+	for i, k := range expectedOrder {
+		if taskOrder[i] != k {
+			t.Errorf("Error expected element %d in order to be %s but got %s", i, k, taskOrder[i])
+		}
+	}
+}
+
+func TestDependsOnUnknown(t *testing.T) {
+	stackString := `
+	components: {
+		worker: {
+			$metadata: {
+				id: "worker"
+				dependsOn: ["queue"]
+			}
+		}
+		app: {
+			$metadata: {
+				id: "app"
+				dependsOn: ["migrate"]
+			}
+		}
+	}
+	`
+	ctx := cuecontext.New()
+	value := ctx.CompileString(stackString)
+
+	for i := 0; i < 10; i++ {
+		_, err := NewStack(value, "", []string{})
+		if err == nil || err.Error() != "component app depends on unknown component migrate" {
+			t.Fatalf("Expected unknown component error but got %v", err)
+		}
+	}
+}
+
+func TestDependsOnCycle(t *testing.T) {
+	stackString := `
+	components: {
+		a: {
+			$metadata: {
+				id: "a"
+				dependsOn: ["c"]
+			}
+		}
+		b: {
+			$metadata: id: "b"
+			todo: a.$metadata.id
+		}
+		c: {
+			$metadata: {
+				id: "c"
+				dependsOn: ["b"]
+			}
+		}
+	}
+	`
+	ctx := cuecontext.New()
+	value := ctx.CompileString(stackString)
+
+	_, err := NewStack(value, "", []string{})
+	if err == nil || err.Error() != "dependency cycle detected between components: a -> c -> b -> a" {
+		t.Errorf("Expected dependency cycle error but got %v", err)
+	}
+}
+
+func TestBuildDataRedacted(t *testing.T) {
+	redact.Add("hunter2-password")
+	redact.AddPath("db.pin")
+
+	value := cuecontext.New().CompileString(`
+components: {
+	db: {
+		$metadata: id: "db"
+		password: "hunter2-password"
+		pin:      1234
+		url:      "postgres://admin:hunter2-password@db"
+	}
+}
+`)
+	s, err := NewStack(value, "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.BuildSource = `password: "hunter2-password" @guku(secret)`
+
+	for _, buildError := range []*string{nil, &s.BuildSource} {
+		build, err := s.newBuildData("dev", buildError)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(build)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "hunter2-password") || strings.Contains(string(data), "1234") {
+			t.Errorf("Expected secrets to be masked in the upload but found %s", data)
+		}
+	}
+}
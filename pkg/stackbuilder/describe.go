@@ -0,0 +1,112 @@
+package stackbuilder
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/format"
+)
+
+// Description is a serializable summary of a resolved stack builder
+type Description struct {
+	Environment   string                  `json:"environment" yaml:"environment"`
+	Version       string                  `json:"version" yaml:"version"`
+	Drivers       map[string]DriverConfig `json:"drivers" yaml:"drivers"`
+	Flows         []FlowDescription       `json:"flows" yaml:"flows"`
+	StackPipeline []string                `json:"stackPipeline" yaml:"stackPipeline"`
+	HasTaskfile   bool                    `json:"taskfile" yaml:"taskfile"`
+}
+
+type FlowDescription struct {
+	Name     string      `json:"name" yaml:"name"`
+	Match    interface{} `json:"match,omitempty" yaml:"match,omitempty"`
+	Exclude  interface{} `json:"exclude,omitempty" yaml:"exclude,omitempty"`
+	Pipeline []string    `json:"pipeline" yaml:"pipeline"`
+}
+
+func (sb *StackBuilder) Describe(environment string) (*Description, error) {
+	description := Description{
+		Environment:   environment,
+		Version:       sb.Version,
+		Drivers:       sb.DriverConfig,
+		Flows:         []FlowDescription{},
+		StackPipeline: transformerNames(sb.StackPipeline),
+		HasTaskfile:   sb.Taskfile != nil,
+	}
+
+	for _, flow := range sb.Flows {
+		match, err := describeValue(flow.match)
+		if err != nil {
+			return nil, err
+		}
+		exclude, err := describeValue(flow.exclude)
+		if err != nil {
+			return nil, err
+		}
+		description.Flows = append(description.Flows, FlowDescription{
+			Name:     flow.Name(),
+			Match:    match,
+			Exclude:  exclude,
+			Pipeline: flow.GetTransformerNames(),
+		})
+	}
+
+	return &description, nil
+}
+
+// describeValue decodes concrete values and falls back to CUE syntax for
+// values like `traits: Workload: _`
+func describeValue(value cue.Value) (interface{}, error) {
+	var result interface{}
+	if err := value.Decode(&result); err == nil {
+		return result, nil
+	}
+
+	source, err := format.Node(value.Syntax(), format.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	return string(source), nil
+}
+
+// GetTransformerNames returns the referenced definition of each pipeline
+// transformer, e.g. compose.#AddComposeService
+func (f *Flow) GetTransformerNames() []string {
+	return transformerNames(f.pipeline)
+}
+
+func transformerNames(pipeline []cue.Value) []string {
+	names := []string{}
+	for i, transformer := range pipeline {
+		name := transformerName(transformer.Source())
+		if name == "" {
+			if _, path := transformer.ReferencePath(); len(path.Selectors()) > 0 {
+				name = path.String()
+			} else {
+				name = fmt.Sprintf("(anonymous %d)", i)
+			}
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func transformerName(node ast.Node) string {
+	switch expr := node.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		name, err := format.Node(expr)
+		if err != nil {
+			return ""
+		}
+		return string(name)
+	case *ast.BinaryExpr:
+		if name := transformerName(expr.X); name != "" {
+			return name
+		}
+		return transformerName(expr.Y)
+	}
+	return ""
+}
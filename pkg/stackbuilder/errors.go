@@ -0,0 +1,49 @@
+package stackbuilder
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResolutionError describes a generated field that could not be resolved
+// by its provider
+type ResolutionError struct {
+	Component string
+	Path      string
+	Provider  string
+	Reason    string
+}
+
+func (e *ResolutionError) Error() string {
+	return fmt.Sprintf("%s.%s: %s provider failed: %s", e.Component, e.Path, e.Provider, e.Reason)
+}
+
+// ResolutionErrors collects all resolution failures of a build so that they
+// are reported together
+type ResolutionErrors []*ResolutionError
+
+func (e ResolutionErrors) Error() string {
+	lines := []string{fmt.Sprintf("failed to resolve %d generated field(s):", len(e))}
+	for _, err := range e {
+		lines = append(lines, fmt.Sprintf("  - %s", err.Error()))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// merge appends errors not already reported, generated fields are resolved
+// by every matching flow so the same failure can be reported more than once
+func (e ResolutionErrors) merge(errs ResolutionErrors) ResolutionErrors {
+	for _, err := range errs {
+		found := false
+		for _, existing := range e {
+			if existing.Component == err.Component && existing.Path == err.Path {
+				found = true
+				break
+			}
+		}
+		if !found {
+			e = append(e, err)
+		}
+	}
+	return e
+}
@@ -3,17 +3,20 @@ package stackbuilder
 import (
 	"context"
 	"fmt"
-	"os"
-	"path/filepath"
 	"strings"
 
 	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/stakpak/devx/pkg/providers"
+	"github.com/stakpak/devx/pkg/redact"
 	"github.com/stakpak/devx/pkg/stack"
 	"github.com/stakpak/devx/pkg/utils"
 )
 
 type Flow struct {
+	name     string
+	policy   string
 	match    cue.Value
 	exclude  cue.Value
 	pipeline []cue.Value
@@ -33,7 +36,13 @@ func NewFlow(value cue.Value) (*Flow, error) {
 		return nil, pipelineValue.Err()
 	}
 
+	name := ""
+	if selectors := value.Path().Selectors(); len(selectors) > 0 {
+		name = strings.Trim(selectors[len(selectors)-1].String(), "\"")
+	}
+
 	flow := Flow{
+		name:     name,
 		match:    matchValue,
 		exclude:  excludeValue,
 		pipeline: make([]cue.Value, 0),
@@ -46,6 +55,40 @@ func NewFlow(value cue.Value) (*Flow, error) {
 	return &flow, nil
 }
 
+// NewPipelineFlow creates a flow running pipeline on every component
+func NewPipelineFlow(name string, pipeline []cue.Value) *Flow {
+	ctx := pipeline[0].Context()
+	return &Flow{
+		name:     name,
+		match:    ctx.CompileString("{}"),
+		exclude:  ctx.CompileString("{}"),
+		pipeline: pipeline,
+	}
+}
+
+// NewPolicyFlow creates a flow enforcing a global policy transformer, it
+// matches components with the traits required by the transformer
+func NewPolicyFlow(policy string, transformer cue.Value) *Flow {
+	match := transformer.Context().CompileString("{}")
+	traits := transformer.LookupPath(cue.ParsePath("$metadata.traits"))
+	if traits.Exists() {
+		match = match.FillPath(cue.ParsePath("traits"), traits)
+	}
+
+	return &Flow{
+		name:     policy,
+		policy:   policy,
+		match:    match,
+		exclude:  transformer.Context().CompileString("{}"),
+		pipeline: []cue.Value{transformer},
+	}
+}
+
+// Name returns the flow label for v2 builders or its index for v1 builders
+func (f *Flow) Name() string {
+	return f.name
+}
+
 func (f *Flow) GetHandledTraits() []string {
 	traits := []string{}
 	traitIter, _ := f.match.LookupPath(cue.ParsePath("traits")).Fields()
@@ -56,10 +99,16 @@ func (f *Flow) GetHandledTraits() []string {
 }
 
 func (f *Flow) Match(component cue.Value) bool {
+	return Match(f.match, f.exclude, component)
+}
+
+// Match reports whether the component metadata is subsumed by match and
+// does not contain any of the values in exclude
+func Match(match cue.Value, exclude cue.Value, component cue.Value) bool {
 	metadata := component.LookupPath(cue.ParsePath("$metadata"))
 
 	// Check matches
-	matchIter, _ := f.match.Fields()
+	matchIter, _ := match.Fields()
 	for matchIter.Next() {
 		fieldName := utils.GetLastPathFragment(matchIter.Value())
 		componentField := metadata.LookupPath(cue.ParsePath(fieldName))
@@ -75,7 +124,7 @@ func (f *Flow) Match(component cue.Value) bool {
 	}
 
 	// Check excludes
-	excludeIter, _ := f.exclude.Fields()
+	excludeIter, _ := exclude.Fields()
 	for excludeIter.Next() {
 		fieldName := utils.GetLastPathFragment(excludeIter.Value())
 		componentField := metadata.LookupPath(cue.ParsePath(fieldName))
@@ -105,66 +154,109 @@ func (f *Flow) Run(ctx context.Context, stack *stack.Stack, componentId string,
 	}
 
 	// Transform
-	component = component.FillPath(cue.ParsePath("$dependencies"), dependencies)
+	if f.wantsDependencyOutputs() {
+		outputs := component.Context().CompileString("{}")
+		for _, id := range dependencies {
+			dependency, err := stack.GetComponent(id)
+			if err != nil {
+				return component, err
+			}
+			outputs = outputs.FillPath(cue.MakePath(cue.Str(id)), dependency)
+		}
+		component = component.FillPath(cue.ParsePath("$dependencies"), outputs)
+	} else {
+		component = component.FillPath(cue.ParsePath("$dependencies"), dependencies)
+	}
 	for _, transformer := range f.pipeline {
 		component = component.FillPath(cue.ParsePath(""), transformer)
 		if component.Err() != nil {
+			if f.policy != "" {
+				return component, fmt.Errorf("component %s violates policy %s:\n%s", componentId, f.policy, errors.Details(component.Err(), nil))
+			}
 			return component, component.Err()
 		}
 	}
-	component = populateGeneratedFields(ctx, component)
+	component, resolutionErrs := populateGeneratedFields(ctx, component)
 	if component.Err() != nil {
 		return component, component.Err()
 	}
+	if len(resolutionErrs) > 0 {
+		for _, err := range resolutionErrs {
+			err.Component = componentId
+		}
+		return component, resolutionErrs
+	}
 
 	return component, nil
 }
 
-func populateGeneratedFields(ctx context.Context, value cue.Value) cue.Value {
+// wantsDependencyOutputs reports whether any transformer in the pipeline
+// declares $dependencies as a struct, in which case it receives the already
+// transformed dependency components keyed by id instead of a list of ids
+func (f *Flow) wantsDependencyOutputs() bool {
+	for _, transformer := range f.pipeline {
+		dependencies := transformer.LookupPath(cue.ParsePath("$dependencies"))
+		if dependencies.Exists() && dependencies.IncompleteKind() == cue.StructKind {
+			return true
+		}
+	}
+	return false
+}
+
+// populateGeneratedFields fills @guku generated fields using their providers,
+// fields that fail to resolve are returned as resolution errors unless
+// utils.AllowMissingKey is set in which case typed placeholders are used,
+// fields skipped in dry runs always use placeholders
+func populateGeneratedFields(ctx context.Context, value cue.Value) (cue.Value, ResolutionErrors) {
+	allowMissing, _ := ctx.Value(utils.AllowMissingKey).(bool)
+	componentSelectors := len(value.Path().Selectors())
 	pathsToFill := []cue.Path{}
-	valuesToFill := []string{}
+	valuesToFill := []interface{}{}
+	resolutionErrs := ResolutionErrors{}
 	utils.Walk(value, func(v cue.Value) bool {
 		gukuAttr := v.Attribute("guku")
 		if !v.IsConcrete() && gukuAttr.Err() == nil {
-			valueToFill := ""
+			key, provider, found := providers.Lookup(gukuAttr)
+			if !found {
+				return true
+			}
 
-			filePath, found, _ := gukuAttr.Lookup(0, "file")
-			if found {
-				if !strings.HasPrefix(filePath, "/") {
-					configDir := ctx.Value(utils.ConfigDirKey).(string)
-					filePath = filepath.Join(configDir, filePath)
-				}
-				filePath, err := verifyPath(filePath)
-				if err != nil {
-					log.Errorf("\nPath error %s\n", err)
-					return true
-				}
-				content, err := os.ReadFile(filePath)
-				if err != nil {
-					log.Errorf("\nFile error %s\n", err)
+			selectors := v.Path().Selectors()
+			path := cue.MakePath(selectors[componentSelectors:]...)
+
+			fieldCtx := context.WithValue(ctx, utils.SourceFilesKey, sourceFiles(v))
+			valueToFill, err := provider.Resolve(fieldCtx, gukuAttr)
+			if err == providers.ErrSkipped {
+				if placeholder, ok := missingPlaceholder(v); ok {
+					pathsToFill = append(pathsToFill, path)
+					valuesToFill = append(valuesToFill, placeholder)
 					return true
 				}
-				valueToFill = string(content)
 			}
-
-			env, found, _ := gukuAttr.Lookup(0, "env")
-			if found && valueToFill == "" {
-				content, found := os.LookupEnv(env)
-				if !found {
-					log.Errorf("\nEnvironment variable %s not set\n", env)
+			if err != nil {
+				placeholder, ok := missingPlaceholder(v)
+				if !allowMissing || !ok {
+					resolutionErrs = append(resolutionErrs, &ResolutionError{
+						Path:     path.String(),
+						Provider: key,
+						Reason:   err.Error(),
+					})
 					return true
 				}
-				valueToFill = content
+				log.Warnf("%s provider error %s, using placeholder for %s", key, err, path)
+				pathsToFill = append(pathsToFill, path)
+				valuesToFill = append(valuesToFill, placeholder)
+				return true
 			}
 
-			isGenerated, _ := gukuAttr.Flag(0, "generate")
-			if isGenerated && valueToFill == "" {
-				valueToFill = "dummy"
+			isSecret, _ := gukuAttr.Flag(0, "secret")
+			if sensitive, ok := provider.(providers.SensitiveProvider); isSecret || ok && sensitive.IsSensitive() {
+				redact.AddSensitive(fmt.Sprint(valueToFill))
+				redact.AddPath(cue.MakePath(selectors[componentSelectors-1:]...).String())
 			}
 
-			if valueToFill != "" {
-				selectors := v.Path().Selectors()
-				pathsToFill = append(pathsToFill, cue.MakePath(selectors[3:]...))
+			if valueToFill != nil && valueToFill != "" {
+				pathsToFill = append(pathsToFill, path)
 				valuesToFill = append(valuesToFill, valueToFill)
 			}
 		}
@@ -174,18 +266,74 @@ func populateGeneratedFields(ctx context.Context, value cue.Value) cue.Value {
 	for i, path := range pathsToFill {
 		value = value.FillPath(path, valuesToFill[i])
 		if value.Err() != nil {
-			return value
+			return value, resolutionErrs
 		}
 	}
 
-	return value
+	return value, resolutionErrs
 }
 
-func verifyPath(path string) (string, error) {
-	c := filepath.Clean(path)
-	r, err := filepath.EvalSymlinks(c)
-	if err != nil {
-		return c, fmt.Errorf("unsafe or invalid path specified: %s", err)
+// sourceFiles lists the files declaring v, unified values are declared in
+// the files of each of their conjuncts
+func sourceFiles(v cue.Value) []string {
+	files := []string{v.Pos().Filename()}
+	if op, args := v.Expr(); op == cue.AndOp {
+		for _, arg := range args {
+			files = append(files, sourceFiles(arg)...)
+		}
+	}
+	return files
+}
+
+// missingPlaceholder returns a placeholder matching the kind expected by v
+func missingPlaceholder(v cue.Value) (interface{}, bool) {
+	kind := v.IncompleteKind()
+	switch {
+	case kind&cue.StringKind != 0:
+		return "MISSING", true
+	case kind&cue.BytesKind != 0:
+		return []byte("MISSING"), true
+	case kind&cue.IntKind != 0:
+		return 0, true
+	case kind&cue.FloatKind != 0:
+		return 0.0, true
+	case kind&cue.BoolKind != 0:
+		return false, true
 	}
-	return r, nil
+	return nil, false
+}
+
+// RegisterSecrets marks concrete values of fields annotated with
+// @guku(secret) as sensitive
+func RegisterSecrets(component cue.Value) {
+	componentSelectors := len(component.Path().Selectors())
+	utils.Walk(component, func(v cue.Value) bool {
+		gukuAttr := v.Attribute("guku")
+		if gukuAttr.Err() != nil {
+			return true
+		}
+		if isSecret, _ := gukuAttr.Flag(0, "secret"); !isSecret {
+			return true
+		}
+
+		selectors := v.Path().Selectors()
+		redact.AddPath(cue.MakePath(selectors[componentSelectors-1:]...).String())
+		// scalar secrets are masked whatever their value, leaves of secret
+		// structs are also masked by path so trivial ones are skipped
+		add := redact.Add
+		if v.Kind() != cue.StructKind && v.Kind() != cue.ListKind {
+			add = redact.AddSensitive
+		}
+		utils.Walk(v, func(leaf cue.Value) bool {
+			switch leaf.Kind() {
+			case cue.StringKind:
+				secret, _ := leaf.String()
+				add(secret)
+			case cue.BytesKind, cue.IntKind, cue.FloatKind:
+				add(fmt.Sprint(leaf))
+			}
+			return true
+		}, nil)
+		return false
+	}, nil)
 }
@@ -1,9 +1,12 @@
 package stackbuilder
 
 import (
+	"context"
 	"testing"
 
+	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/stack"
 )
 
 var flowString1 = `
@@ -109,3 +112,60 @@ func TestMatchExcludeFlow(t *testing.T) {
 		t.Error("Expected component not to match flow: excluded label")
 	}
 }
+
+var flowDependencyOutputs = `
+match: {}
+exclude: {}
+pipeline: [
+	{
+		$dependencies: [string]: _
+		url: "postgres://\($dependencies.db.host):\($dependencies.db.port)"
+	},
+]
+`
+
+var stackDependencyOutputs = `
+components: {
+	db: {
+		$metadata: id: "db"
+		host: "db.internal"
+		port: 5432
+	}
+	app: {
+		$metadata: id: "app"
+		dbPort: db.port
+	}
+}
+`
+
+func TestRunFlowDependencyOutputs(t *testing.T) {
+	ctx := cuecontext.New()
+
+	flow, err := NewFlow(ctx.CompileString(flowDependencyOutputs))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := stack.NewStack(ctx.CompileString(stackDependencyOutputs), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	component, err := s.GetComponent("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	component, err = flow.Run(context.Background(), s, "app", component)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	url, err := component.LookupPath(cue.ParsePath("url")).String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url != "postgres://db.internal:5432" {
+		t.Errorf("Expected url postgres://db.internal:5432 but found %s", url)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,6 +13,7 @@ import (
 	"cuelang.org/go/cue/errors"
 	"github.com/schollz/progressbar/v3"
 	log "github.com/sirupsen/logrus"
+	"github.com/stakpak/devx/pkg/diagnostics"
 	"github.com/stakpak/devx/pkg/stack"
 	"github.com/stakpak/devx/pkg/utils"
 )
@@ -19,9 +21,11 @@ import (
 type Environments = map[string]*StackBuilder
 
 type StackBuilder struct {
+	Version              string
 	DriverConfig         map[string]DriverConfig
 	AdditionalComponents *cue.Value
 	Flows                []*Flow
+	StackPipeline        []cue.Value
 	Taskfile             *cue.Value
 }
 type DriverConfig struct {
@@ -164,13 +168,31 @@ func NewStackBuilder(environment string, value cue.Value) (*StackBuilder, error)
 		}
 	}
 
+	version := "v1"
+	if isV2Builder {
+		version = "v2"
+	}
+
 	stackBuilder := StackBuilder{
+		Version:              version,
 		DriverConfig:         driverConfig,
 		AdditionalComponents: additionalComponents,
 		Flows:                make([]*Flow, 0),
+		StackPipeline:        make([]cue.Value, 0),
 		Taskfile:             taskfile,
 	}
 
+	stackPipeline := value.LookupPath(cue.ParsePath("stackPipeline"))
+	if stackPipeline.Exists() {
+		stackPipelineIter, err := stackPipeline.List()
+		if err != nil {
+			return nil, err
+		}
+		for stackPipelineIter.Next() {
+			stackBuilder.StackPipeline = append(stackBuilder.StackPipeline, stackPipelineIter.Value())
+		}
+	}
+
 	if isV2Builder {
 		flowIter, err := flows.Fields()
 		if err != nil {
@@ -202,7 +224,9 @@ func NewStackBuilder(environment string, value cue.Value) (*StackBuilder, error)
 
 func (sb *StackBuilder) TransformStack(ctx context.Context, stack *stack.Stack) error {
 	if sb.AdditionalComponents != nil {
-		stack.AddComponents(*sb.AdditionalComponents)
+		if err := stack.AddComponents(*sb.AdditionalComponents); err != nil {
+			return err
+		}
 	}
 	orderedTasks := stack.GetTasks()
 
@@ -210,6 +234,7 @@ func (sb *StackBuilder) TransformStack(ctx context.Context, stack *stack.Stack)
 	for _, flow := range sb.Flows {
 		total += len(orderedTasks) * len(flow.pipeline)
 	}
+	total += len(sb.StackPipeline)
 
 	progressWriter := log.StandardLogger().Out
 	if log.GetLevel() == log.ErrorLevel {
@@ -231,17 +256,34 @@ func (sb *StackBuilder) TransformStack(ctx context.Context, stack *stack.Stack)
 		progressbar.OptionSetRenderBlankState(true),
 	)
 	defer bar.Finish()
+	resolutionErrs := ResolutionErrors{}
 	for _, componentId := range orderedTasks {
 		component, err := stack.GetComponent(componentId)
 		if err != nil {
 			return err
 		}
+		// once fields are unresolved, later errors are usually caused by
+		// components referencing them, so these components are skipped and
+		// the unresolved fields are reported instead
+		unresolved := false
 		for _, flow := range sb.Flows {
 			component, err = flow.Run(ctx, stack, componentId, component)
-			if err != nil {
+			if errs, ok := err.(ResolutionErrors); ok {
+				// keep transforming to report all unresolved fields together
+				resolutionErrs = resolutionErrs.merge(errs)
+				unresolved = true
+			} else if err != nil {
+				if len(resolutionErrs) > 0 {
+					unresolved = true
+					break
+				}
 				return err
 			}
 			if !stack.HasConcreteResourceDrivers(component) {
+				if len(resolutionErrs) > 0 {
+					unresolved = true
+					break
+				}
 				return fmt.Errorf(
 					"component %s resources do not have concrete drivers",
 					componentId,
@@ -249,51 +291,146 @@ func (sb *StackBuilder) TransformStack(ctx context.Context, stack *stack.Stack)
 			}
 			bar.Add(len(flow.pipeline))
 		}
+		if unresolved {
+			// concreteness errors would only repeat the unresolved fields
+			continue
+		}
 		if !stack.IsConcreteComponent(component) {
+			if len(resolutionErrs) > 0 {
+				continue
+			}
 			err := component.Validate(cue.Concrete(true), cue.All())
 			log.Debugln(component)
 			return fmt.Errorf("component %s is not concrete after transformation:\n%s", componentId, errors.Details(err, nil))
 		}
+		RegisterSecrets(component)
 		stack.UpdateComponent(componentId, component)
 	}
+	if len(resolutionErrs) > 0 {
+		return resolutionErrs
+	}
+
+	for _, transformer := range sb.StackPipeline {
+		if err := runStackTransformer(stack, transformer); err != nil {
+			return err
+		}
+		bar.Add(1)
+	}
 	return nil
 }
 
+// runStackTransformer fills the whole stack into a stack transformer's
+// $components field and merges the transformer's components output back
+// into the stack, allowing it to add resources or new components
+func runStackTransformer(stack *stack.Stack, transformer cue.Value) error {
+	transformer = transformer.FillPath(cue.ParsePath("$components"), stack.GetComponents())
+	if transformer.Err() != nil {
+		return transformer.Err()
+	}
+
+	components := transformer.LookupPath(cue.ParsePath("components"))
+	if !components.Exists() {
+		return nil
+	}
+	if components.Err() != nil {
+		return components.Err()
+	}
+
+	componentIter, err := components.Fields()
+	if err != nil {
+		return err
+	}
+	for componentIter.Next() {
+		selector := componentIter.Selector()
+		if stack.GetComponents().LookupPath(cue.MakePath(selector)).Exists() {
+			continue
+		}
+		// new components need an id to be picked up as tasks by drivers
+		if !componentIter.Value().LookupPath(cue.ParsePath("$metadata.id")).Exists() {
+			components = components.FillPath(
+				cue.MakePath(selector, cue.Str("$metadata"), cue.Str("id")),
+				selector.String(),
+			)
+		}
+	}
+
+	if err := stack.AddComponents(components); err != nil {
+		return err
+	}
+
+	componentIter, _ = components.Fields()
+	for componentIter.Next() {
+		componentId := componentIter.Selector().String()
+		component, err := stack.GetComponent(componentId)
+		if err != nil {
+			return err
+		}
+		if !stack.HasConcreteResourceDrivers(component) {
+			return fmt.Errorf(
+				"component %s resources do not have concrete drivers",
+				componentId,
+			)
+		}
+		if !stack.IsConcreteComponent(component) {
+			err := component.Validate(cue.Concrete(true), cue.All())
+			log.Debugln(component)
+			return fmt.Errorf("component %s is not concrete after stack transformation:\n%s", componentId, errors.Details(err, nil))
+		}
+	}
+
+	return nil
+}
+
+// CheckTraitFulfillment reports component traits that are not handled by any
+// flow in each environment as diagnostics.Diagnostics
 func CheckTraitFulfillment(builders Environments, stack *stack.Stack) error {
 	compIter, err := stack.GetComponents().Fields()
 	if err != nil {
 		return err
 	}
-	// component -> trait -> env -> handled
-	compFlowMap := map[string]map[string]map[string]bool{}
-	unmatched := []string{}
+
+	envs := make([]string, 0, len(builders))
+	for env := range builders {
+		envs = append(envs, env)
+	}
+	sort.Strings(envs)
+
+	unmatched := diagnostics.Diagnostics{}
 	for compIter.Next() {
 		component := compIter.Label()
-		compFlowMap[component] = map[string]map[string]bool{}
+		traits := []string{}
 		traitIter, _ := compIter.Value().LookupPath(cue.ParsePath("$metadata.traits")).Fields()
 		for traitIter.Next() {
-			compFlowMap[component][traitIter.Label()] = map[string]bool{}
+			traits = append(traits, traitIter.Label())
 		}
-		for env, builder := range builders {
-			for trait := range compFlowMap[component] {
-				compFlowMap[component][trait][env] = false
-			}
-			for _, flow := range builder.Flows {
+		for _, env := range envs {
+			handled := map[string]bool{}
+			for _, flow := range builders[env].Flows {
 				if flow.Match(compIter.Value()) {
 					for _, trait := range flow.GetHandledTraits() {
-						compFlowMap[component][trait][env] = true
+						handled[trait] = true
 					}
 				}
 			}
-			for trait := range compFlowMap[component] {
-				if !compFlowMap[component][trait][env] {
-					unmatched = append(unmatched, fmt.Sprintf("%s/#%s in %s", component, trait, env))
+			for _, trait := range traits {
+				if handled[trait] {
+					continue
+				}
+				diagnostic := diagnostics.Diagnostic{
+					Severity:    diagnostics.Error,
+					Rule:        "trait-unfulfilled",
+					Component:   component,
+					Trait:       trait,
+					Environment: env,
+					Message:     fmt.Sprintf("trait %s of component %s is not fulfilled by any flow in %s", trait, component, env),
 				}
+				diagnostic.SetPosition(compIter.Value().Pos())
+				unmatched = append(unmatched, diagnostic)
 			}
 		}
 	}
 	if len(unmatched) > 0 {
-		return fmt.Errorf("trait is not fulfilled by any flow: \n  %s", strings.Join(unmatched, "\n  "))
+		return unmatched
 	}
 	return nil
 }
@@ -0,0 +1,374 @@
+package stackbuilder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/redact"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/utils"
+)
+
+var stackPipelineBuilder = `
+environment: "dev"
+flows: {}
+stackPipeline: [
+	{
+		$components: [string]: _
+		components: {
+			gateway: {
+				routes: [for id, c in $components if c.exposed != _|_ {id}]
+			}
+			for id, c in $components if c.exposed != _|_ {
+				"\(id)": $resources: route: {
+					$metadata: labels: driver: "compose"
+					host: "\(id).local"
+				}
+			}
+		}
+	},
+]
+`
+
+var stackPipelineStack = `
+components: {
+	web: {
+		$metadata: id: "web"
+		exposed: true
+	}
+	worker: {
+		$metadata: id: "worker"
+	}
+}
+`
+
+func TestStackPipeline(t *testing.T) {
+	ctx := cuecontext.New()
+
+	builder, err := NewStackBuilder("dev", ctx.CompileString(stackPipelineBuilder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(builder.StackPipeline) != 1 {
+		t.Fatalf("Expected 1 stack transformer but found %d", len(builder.StackPipeline))
+	}
+
+	s, err := stack.NewStack(ctx.CompileString(stackPipelineStack), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = builder.TransformStack(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway, err := s.GetComponent("gateway")
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []string{}
+	if err := gateway.LookupPath(cue.ParsePath("routes")).Decode(&routes); err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0] != "web" {
+		t.Errorf("Expected gateway routes [web] but found %v", routes)
+	}
+
+	host, _ := s.GetComponents().LookupPath(cue.ParsePath("web.$resources.route.host")).String()
+	if host != "web.local" {
+		t.Errorf("Expected web route host web.local but found %s", host)
+	}
+
+	found := false
+	for _, task := range s.GetTasks() {
+		if task == "gateway" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected new component gateway to be a task but found %v", s.GetTasks())
+	}
+}
+
+var secretsBuilder = `
+environment: "dev"
+flows: {}
+`
+
+var secretsStack = `
+components: {
+	app: {
+		$metadata: id: "app"
+		token:    string @guku(env="DEVX_TEST_TOKEN")
+		password: "hunter22" @guku(secret)
+		pin:      7391 @guku(secret)
+		name:     "app"
+	}
+}
+`
+
+func TestTransformStackSecrets(t *testing.T) {
+	t.Setenv("DEVX_TEST_TOKEN", "t0k3n-value")
+	ctx := cuecontext.New()
+
+	builder, err := NewStackBuilder("dev", ctx.CompileString(secretsBuilder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.Flows = append(builder.Flows, &Flow{
+		match:    ctx.CompileString("{}"),
+		exclude:  ctx.CompileString("{}"),
+		pipeline: []cue.Value{},
+	})
+
+	s, err := stack.NewStack(ctx.CompileString(secretsStack), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = builder.TransformStack(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, _ := s.GetComponents().LookupPath(cue.ParsePath("app.token")).String()
+	if token != "t0k3n-value" {
+		t.Errorf("Expected token to be filled but found %s", token)
+	}
+
+	output := redact.String(fmt.Sprint(s.GetComponents()))
+	if strings.Contains(output, "t0k3n-value") || strings.Contains(output, "hunter22") || strings.Contains(output, "7391") {
+		t.Errorf("Expected secrets to be masked but found:\n%s", output)
+	}
+	if !strings.Contains(output, `"app"`) {
+		t.Errorf("Expected non sensitive values to be kept but found:\n%s", output)
+	}
+
+	if !redact.IsSensitivePath("app.token") || !redact.IsSensitivePath("app.password") {
+		t.Error("Expected app.token and app.password to be sensitive paths")
+	}
+	if redact.IsSensitivePath("app.name") {
+		t.Error("Expected app.name not to be a sensitive path")
+	}
+}
+
+var missingStack = `
+components: {
+	app: {
+		$metadata: id: "app"
+		token: string @guku(env="DEVX_TEST_MISSING_TOKEN")
+		port:  int @guku(env="DEVX_TEST_MISSING_PORT")
+	}
+	db: {
+		$metadata: id: "db"
+		password: string @guku(file="missing/password.txt")
+	}
+}
+`
+
+func TestTransformStackResolutionErrors(t *testing.T) {
+	ctx := cuecontext.New()
+
+	newStack := func() (*StackBuilder, *stack.Stack) {
+		builder, err := NewStackBuilder("dev", ctx.CompileString(secretsBuilder))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			builder.Flows = append(builder.Flows, &Flow{
+				match:    ctx.CompileString("{}"),
+				exclude:  ctx.CompileString("{}"),
+				pipeline: []cue.Value{},
+			})
+		}
+		s, err := stack.NewStack(ctx.CompileString(missingStack), "", []string{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return builder, s
+	}
+
+	builder, s := newStack()
+	err := builder.TransformStack(context.Background(), s)
+	errs, ok := err.(ResolutionErrors)
+	if !ok {
+		t.Fatalf("Expected resolution errors but found %v", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("Expected 3 resolution errors but found %d:\n%s", len(errs), errs)
+	}
+	providersByPath := map[string]string{}
+	for _, err := range errs {
+		providersByPath[err.Component+"."+err.Path] = err.Provider
+	}
+	for path, provider := range map[string]string{"app.token": "env", "app.port": "env", "db.password": "file"} {
+		if providersByPath[path] != provider {
+			t.Errorf("Expected %s provider error for %s but found:\n%s", provider, path, errs)
+		}
+	}
+
+	builder, s = newStack()
+	allowMissingCtx := context.WithValue(context.Background(), utils.AllowMissingKey, true)
+	err = builder.TransformStack(allowMissingCtx, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, _ := s.GetComponents().LookupPath(cue.ParsePath("app.port")).Int64()
+	token, _ := s.GetComponents().LookupPath(cue.ParsePath("app.token")).String()
+	if port != 0 || token != "MISSING" {
+		t.Errorf("Expected placeholders but found port %d token %s", port, token)
+	}
+}
+
+var referencedMissingStack = `
+components: {
+	db: {
+		$metadata: id: "db"
+		password: string @guku(env="DEVX_TEST_MISSING_PASSWORD")
+	}
+	app: {
+		$metadata: id: "app"
+		env: DB_PASSWORD: db.password
+	}
+}
+`
+
+func TestTransformStackReferencedResolutionErrors(t *testing.T) {
+	ctx := cuecontext.New()
+
+	builder, err := NewStackBuilder("dev", ctx.CompileString(secretsBuilder))
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.Flows = append(builder.Flows, &Flow{
+		match:    ctx.CompileString("{}"),
+		exclude:  ctx.CompileString("{}"),
+		pipeline: []cue.Value{},
+	})
+	s, err := stack.NewStack(ctx.CompileString(referencedMissingStack), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = builder.TransformStack(context.Background(), s)
+	errs, ok := err.(ResolutionErrors)
+	if !ok {
+		t.Fatalf("Expected resolution errors but found %v", err)
+	}
+	if len(errs) != 1 || errs[0].Component != "db" || errs[0].Path != "password" {
+		t.Errorf("Expected db.password to be reported but found:\n%s", errs)
+	}
+}
+
+var describeBuilders = `
+#AddService: {$resources: service: {$metadata: labels: driver: "compose"}}
+#AddPort: {$resources: service: port: *80 | int}
+v2: {
+	environment: "dev"
+	flows: web: {
+		match: traits: Workload: null
+		exclude: {}
+		pipeline: [#AddService, #AddPort & {$resources: service: port: 8080}, {}]
+	}
+	stackPipeline: [#AddPort]
+	drivers: compose: output: {dir: ["build", "dev"], file: "compose.yml"}
+}
+v1: {
+	flows: [{
+		match: traits: Workload: null
+		exclude: {}
+		pipeline: [#AddService]
+	}]
+}
+`
+
+func TestDescribe(t *testing.T) {
+	builders := cuecontext.New().CompileString(describeBuilders)
+
+	v2, err := NewStackBuilder("dev", builders.LookupPath(cue.ParsePath("v2")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, err := v2.Describe("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Version != "v2" || len(description.Flows) != 1 || description.Flows[0].Name != "web" {
+		t.Fatalf("Unexpected description %+v", description)
+	}
+	expected := []string{"#AddService", "#AddPort", "(anonymous 2)"}
+	if fmt.Sprint(description.Flows[0].Pipeline) != fmt.Sprint(expected) {
+		t.Errorf("Expected pipeline %v but found %v", expected, description.Flows[0].Pipeline)
+	}
+	if fmt.Sprint(description.StackPipeline) != "[#AddPort]" {
+		t.Errorf("Expected stack pipeline [#AddPort] but found %v", description.StackPipeline)
+	}
+	if description.Drivers["compose"].Output.Dir != "build/dev" {
+		t.Errorf("Expected compose output dir build/dev but found %s", description.Drivers["compose"].Output.Dir)
+	}
+
+	v1, err := NewStackBuilder("prod", builders.LookupPath(cue.ParsePath("v1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	description, err = v1.Describe("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if description.Version != "v1" || description.Flows[0].Name != "0" {
+		t.Errorf("Unexpected description %+v", description)
+	}
+	if description.Drivers["terraform"].Output.Dir != "build/prod/terraform" {
+		t.Errorf("Expected default terraform output dir but found %s", description.Drivers["terraform"].Output.Dir)
+	}
+}
+
+var policyStack = `
+components: {
+	app: {
+		$metadata: {
+			id: "app"
+			traits: Workload: null
+		}
+		privileged: true
+	}
+	db: {
+		$metadata: {
+			id: "db"
+			traits: Postgres: null
+		}
+		privileged: true
+	}
+}
+`
+
+func TestPolicyFlow(t *testing.T) {
+	ctx := cuecontext.New()
+
+	transformer := ctx.CompileString(`{
+		$metadata: traits: Workload: null
+		privileged: false
+	}`)
+	flow := NewPolicyFlow("no-privileged", transformer)
+
+	s, err := stack.NewStack(ctx.CompileString(policyStack), "", []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := s.GetComponent("db")
+	if _, err := flow.Run(context.Background(), s, "db", db); err != nil {
+		t.Errorf("Expected db not to be matched by the policy but found %s", err)
+	}
+
+	app, _ := s.GetComponent("app")
+	_, err = flow.Run(context.Background(), s, "app", app)
+	if err == nil || !strings.Contains(err.Error(), "component app violates policy no-privileged") {
+		t.Errorf("Expected policy violation but found %v", err)
+	}
+}
@@ -15,6 +15,7 @@ import (
 
 	"github.com/stakpak/devx/pkg/auth"
 	"github.com/stakpak/devx/pkg/gitrepo"
+	"github.com/stakpak/devx/pkg/redact"
 	"github.com/stakpak/devx/pkg/stackbuilder"
 	"github.com/stakpak/devx/pkg/utils"
 
@@ -46,11 +47,14 @@ type RunFlags struct {
 }
 
 func Run(configDir string, buildersPath string, server auth.ServerConfig, runFlags RunFlags, environment string, doubleDashPos int, args []string) error {
+	if err := utils.ValidateEnvironment(environment); err != nil {
+		return err
+	}
 	overlays, err := utils.GetOverlays(configDir)
 	if err != nil {
 		return err
 	}
-	value, stackId, _ := utils.LoadProject(configDir, &overlays)
+	value, stackId, _ := utils.LoadProject(configDir, &overlays, environment)
 	err = value.Validate()
 	if err != nil {
 		return err
@@ -75,6 +79,7 @@ func Run(configDir string, buildersPath string, server auth.ServerConfig, runFla
 		return fmt.Errorf("no taskfile definition found in environment %s", environment)
 	}
 
+	stackbuilder.RegisterSecrets(*builder.Taskfile)
 	log.Debug(builder.Taskfile)
 	err = builder.Taskfile.Validate(cue.Concrete(true))
 	if err != nil {
@@ -240,17 +245,7 @@ type TaskData struct {
 }
 
 func sendTask(configDir string, server auth.ServerConfig, environment string, stack string, source string, tasks map[string]map[string]string, globals map[string]string, taskError *string, taskOutput *string) (string, error) {
-	taskData := TaskData{
-		Stack:       stack,
-		Identity:    "",
-		Environment: environment,
-		Git:         nil,
-		Source:      source,
-		Tasks:       tasks,
-		Globals:     globals,
-		Error:       taskError,
-		Output:      taskOutput,
-	}
+	taskData := newTaskData(environment, stack, source, tasks, globals, taskError, taskOutput)
 
 	gitData, err := gitrepo.GetGitData(configDir)
 	if err != nil {
@@ -258,7 +253,7 @@ func sendTask(configDir string, server auth.ServerConfig, environment string, st
 	}
 	taskData.Git = gitData
 
-	data, err := utils.SendData(server, "tasks", &taskData)
+	data, err := utils.SendData(server, "tasks", taskData)
 	if err != nil {
 		return "", err
 	}
@@ -271,3 +266,36 @@ func sendTask(configDir string, server auth.ServerConfig, environment string, st
 
 	return taskResponse["id"], nil
 }
+
+// newTaskData builds the uploaded task run with all sensitive values masked
+func newTaskData(environment string, stack string, source string, tasks map[string]map[string]string, globals map[string]string, taskError *string, taskOutput *string) *TaskData {
+	taskData := TaskData{
+		Stack:       stack,
+		Identity:    "",
+		Environment: environment,
+		Git:         nil,
+		Source:      redact.String(source),
+		Tasks:       map[string]map[string]string{},
+		Globals:     map[string]string{},
+		Error:       redactedString(taskError),
+		Output:      redactedString(taskOutput),
+	}
+	for task, args := range tasks {
+		taskData.Tasks[task] = map[string]string{}
+		for k, v := range args {
+			taskData.Tasks[task][k] = redact.String(v)
+		}
+	}
+	for k, v := range globals {
+		taskData.Globals[k] = redact.String(v)
+	}
+	return &taskData
+}
+
+func redactedString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	redacted := redact.String(*s)
+	return &redacted
+}
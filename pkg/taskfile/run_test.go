@@ -0,0 +1,31 @@
+package taskfile
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stakpak/devx/pkg/redact"
+)
+
+func TestTaskDataRedacted(t *testing.T) {
+	redact.Add("hunter2-password")
+
+	source := "tasks:\n  migrate:\n    env:\n      PASSWORD: hunter2-password\n"
+	output := "connecting with hunter2-password"
+	taskError := "failed to authenticate hunter2-password"
+	taskData := newTaskData(
+		"dev", "app", source,
+		map[string]map[string]string{"migrate": {"PASSWORD": "hunter2-password"}},
+		map[string]string{"PASSWORD": "hunter2-password"},
+		&taskError, &output,
+	)
+
+	data, err := json.Marshal(taskData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2-password") {
+		t.Errorf("Expected secrets to be masked in the upload but found %s", data)
+	}
+}
@@ -0,0 +1,114 @@
+package testrunner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Print writes results in one of the formats text (go test like) or junit
+func Print(w io.Writer, results []Result, format string) error {
+	switch format {
+	case "text":
+		return printText(w, results)
+	case "junit":
+		return printJUnit(w, results)
+	}
+	return fmt.Errorf("unsupported output format %s", format)
+}
+
+func printText(w io.Writer, results []Result) error {
+	failed := 0
+	total := time.Duration(0)
+	for _, result := range results {
+		total += result.Duration
+		fmt.Fprintf(w, "=== RUN   %s\n", result.Name)
+		if result.Passed {
+			fmt.Fprintf(w, "--- PASS: %s (%.2fs)\n", result.Name, result.Duration.Seconds())
+			continue
+		}
+
+		failed++
+		fmt.Fprintf(w, "--- FAIL: %s (%.2fs)\n", result.Name, result.Duration.Seconds())
+		fmt.Fprintf(w, "    %s: %s\n", result.File, indent(result.Message))
+		if result.Diff != "" {
+			fmt.Fprintf(w, "    %s\n", indent(strings.TrimSuffix(result.Diff, "\n")))
+		}
+	}
+
+	if failed > 0 {
+		_, err := fmt.Fprintf(w, "FAIL\t%d of %d test(s) failed\t%.3fs\n", failed, len(results), total.Seconds())
+		return err
+	}
+	_, err := fmt.Fprintf(w, "PASS\t%d test(s)\t%.3fs\n", len(results), total.Seconds())
+	return err
+}
+
+func indent(s string) string {
+	return strings.ReplaceAll(s, "\n", "\n    ")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+func printJUnit(w io.Writer, results []Result) error {
+	suite := junitTestSuite{
+		Name:  "devx",
+		Tests: len(results),
+		Cases: []junitTestCase{},
+	}
+
+	total := time.Duration(0)
+	for _, result := range results {
+		total += result.Duration
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: result.File,
+			Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+		}
+		if !result.Passed {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: result.Message,
+				Content: result.Diff,
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
@@ -0,0 +1,298 @@
+package testrunner
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cuelang.org/go/cue"
+	"github.com/stakpak/devx/pkg/diff"
+	"github.com/stakpak/devx/pkg/policy"
+	"github.com/stakpak/devx/pkg/stack"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+)
+
+var testPath = cue.ParsePath("$metadata.test")
+
+// Fixture is a test case defined in a *_test.cue file
+//
+//	addsService: {
+//		$metadata: test: "adds a compose service"
+//		input: {$metadata: traits: Workload: null, ...}
+//		environment: "dev"             // or flow, policy, transformer, pipeline
+//		expect: $resources: ...        // or expectError: "conflicting values"
+//	}
+type Fixture struct {
+	Name        string
+	File        string
+	Environment string
+	Flow        string
+	Policy      string
+	input       cue.Value
+	pipeline    []cue.Value
+	expect      *cue.Value
+	expectError *string
+}
+
+type Result struct {
+	Name     string
+	File     string
+	Passed   bool
+	Message  string
+	Diff     string
+	Duration time.Duration
+}
+
+// LoadFixtures finds fixtures in the top level fields of the project
+func LoadFixtures(value cue.Value) ([]*Fixture, error) {
+	fixtures := []*Fixture{}
+
+	fieldIter, err := value.Fields()
+	if err != nil {
+		return nil, err
+	}
+	for fieldIter.Next() {
+		item := fieldIter.Value()
+		if !item.LookupPath(testPath).Exists() {
+			continue
+		}
+		fixture, err := NewFixture(item)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, fixture)
+	}
+
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		if fixtures[i].File != fixtures[j].File {
+			return fixtures[i].File < fixtures[j].File
+		}
+		return fixtures[i].Name < fixtures[j].Name
+	})
+
+	return fixtures, nil
+}
+
+func NewFixture(value cue.Value) (*Fixture, error) {
+	name, err := value.LookupPath(testPath).String()
+	if err != nil {
+		return nil, fmt.Errorf("invalid test name %s", value.Path())
+	}
+
+	fixture := Fixture{
+		Name:     name,
+		File:     value.Pos().Filename(),
+		pipeline: []cue.Value{},
+	}
+
+	fixture.input = value.LookupPath(cue.ParsePath("input"))
+	if !fixture.input.Exists() {
+		return nil, fmt.Errorf("test %s has no input", name)
+	}
+
+	for field, target := range map[string]*string{
+		"environment": &fixture.Environment,
+		"flow":        &fixture.Flow,
+		"policy":      &fixture.Policy,
+	} {
+		v := value.LookupPath(cue.ParsePath(field))
+		if !v.Exists() {
+			continue
+		}
+		if *target, err = v.String(); err != nil {
+			return nil, fmt.Errorf("invalid %s in test %s: %s", field, name, err)
+		}
+	}
+
+	if transformer := value.LookupPath(cue.ParsePath("transformer")); transformer.Exists() {
+		fixture.pipeline = append(fixture.pipeline, transformer)
+	}
+	if pipeline := value.LookupPath(cue.ParsePath("pipeline")); pipeline.Exists() {
+		pipelineIter, err := pipeline.List()
+		if err != nil {
+			return nil, fmt.Errorf("invalid pipeline in test %s: %s", name, err)
+		}
+		for pipelineIter.Next() {
+			fixture.pipeline = append(fixture.pipeline, pipelineIter.Value())
+		}
+	}
+
+	targets := 0
+	for _, isSet := range []bool{fixture.Environment != "", fixture.Policy != "", len(fixture.pipeline) > 0} {
+		if isSet {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return nil, fmt.Errorf("test %s must set exactly one of environment, policy, transformer or pipeline", name)
+	}
+	if fixture.Flow != "" && fixture.Environment == "" {
+		return nil, fmt.Errorf("test %s sets a flow without an environment", name)
+	}
+
+	if expect := value.LookupPath(cue.ParsePath("expect")); expect.Exists() {
+		fixture.expect = &expect
+	}
+	if expectError := value.LookupPath(cue.ParsePath("expectError")); expectError.Exists() {
+		message := ""
+		switch expectError.Kind() {
+		case cue.BoolKind:
+			if isExpected, _ := expectError.Bool(); !isExpected {
+				break
+			}
+			fixture.expectError = &message
+		case cue.StringKind:
+			message, _ = expectError.String()
+			fixture.expectError = &message
+		default:
+			return nil, fmt.Errorf("invalid expectError in test %s, expected a bool or string", name)
+		}
+	}
+
+	return &fixture, nil
+}
+
+type Runner struct {
+	Builders stackbuilder.Environments
+	Policies []*policy.Policy
+}
+
+func (r *Runner) Run(ctx context.Context, fixture *Fixture) Result {
+	start := time.Now()
+	output, err := r.transform(ctx, fixture)
+	result := fixture.check(output, err)
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (r *Runner) transform(ctx context.Context, fixture *Fixture) (cue.Value, error) {
+	input := fixture.input
+	componentId, err := input.LookupPath(cue.ParsePath("$metadata.id")).String()
+	if err != nil {
+		componentId = "component"
+		input = input.FillPath(cue.ParsePath("$metadata.id"), componentId)
+	}
+
+	stackValue := input.Context().CompileString("{}").FillPath(
+		cue.MakePath(cue.Str("components"), cue.Str(componentId)),
+		input,
+	)
+	s, err := stack.NewStack(stackValue, "", []string{})
+	if err != nil {
+		return cue.Value{}, err
+	}
+	component, err := s.GetComponent(componentId)
+	if err != nil {
+		return cue.Value{}, err
+	}
+
+	switch {
+	case len(fixture.pipeline) > 0:
+		flow := stackbuilder.NewPipelineFlow(fixture.Name, fixture.pipeline)
+		return flow.Run(ctx, s, componentId, component)
+
+	case fixture.Policy != "":
+		for _, p := range r.Policies {
+			if p.Name != fixture.Policy {
+				continue
+			}
+			for _, transformer := range p.Pipeline {
+				component, err = stackbuilder.NewPolicyFlow(p.Name, transformer).Run(ctx, s, componentId, component)
+				if err != nil {
+					return component, err
+				}
+			}
+			return component, nil
+		}
+		return cue.Value{}, fmt.Errorf("policy %s was not found", fixture.Policy)
+
+	default:
+		builder, ok := r.Builders[fixture.Environment]
+		if !ok {
+			return cue.Value{}, fmt.Errorf("environment %s was not found", fixture.Environment)
+		}
+		if fixture.Flow != "" {
+			for _, flow := range builder.Flows {
+				if flow.Name() == fixture.Flow {
+					return flow.Run(ctx, s, componentId, component)
+				}
+			}
+			return cue.Value{}, fmt.Errorf("flow %s was not found in environment %s", fixture.Flow, fixture.Environment)
+		}
+		if err := builder.TransformStack(ctx, s); err != nil {
+			return cue.Value{}, err
+		}
+		return s.GetComponent(componentId)
+	}
+}
+
+func (f *Fixture) check(output cue.Value, err error) Result {
+	result := Result{
+		Name: f.Name,
+		File: f.File,
+	}
+
+	if err != nil {
+		if f.expectError == nil {
+			result.Message = fmt.Sprintf("unexpected error: %s", err)
+			return result
+		}
+		if !strings.Contains(err.Error(), *f.expectError) {
+			result.Message = fmt.Sprintf("expected error containing %q but found: %s", *f.expectError, err)
+			return result
+		}
+		result.Passed = true
+		return result
+	}
+
+	if f.expectError != nil {
+		result.Message = "expected an error but transformation succeeded"
+		if *f.expectError != "" {
+			result.Message = fmt.Sprintf("expected error containing %q but transformation succeeded", *f.expectError)
+		}
+		return result
+	}
+
+	if f.expect != nil {
+		expected, actual := compareLeaves(*f.expect, output)
+		if d := diff.Unified(expected, actual, "expected", "actual"); d != "" {
+			result.Message = "output does not match expected"
+			result.Diff = d
+			return result
+		}
+	}
+
+	result.Passed = true
+	return result
+}
+
+// compareLeaves renders the concrete leaves of expect and the values found
+// at the same paths in output, fields not mentioned in expect are ignored
+func compareLeaves(expect cue.Value, output cue.Value) (string, string) {
+	expectSelectors := len(expect.Path().Selectors())
+	expected := []string{}
+	actual := []string{}
+
+	expect.Walk(func(v cue.Value) bool {
+		switch v.Kind() {
+		case cue.BoolKind, cue.NumberKind, cue.StringKind, cue.BytesKind, cue.NullKind:
+			path := cue.MakePath(v.Path().Selectors()[expectSelectors:]...)
+			expected = append(expected, fmt.Sprintf("%s: %v", path, v))
+
+			outputValue := output.LookupPath(path)
+			switch {
+			case !outputValue.Exists():
+				actual = append(actual, fmt.Sprintf("%s: <missing>", path))
+			case !outputValue.IsConcrete():
+				actual = append(actual, fmt.Sprintf("%s: <incomplete %v>", path, outputValue))
+			default:
+				actual = append(actual, fmt.Sprintf("%s: %v", path, outputValue))
+			}
+		}
+		return true
+	}, nil)
+
+	return strings.Join(expected, "\n") + "\n", strings.Join(actual, "\n") + "\n"
+}
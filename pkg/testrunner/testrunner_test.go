@@ -0,0 +1,127 @@
+package testrunner
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stakpak/devx/pkg/policy"
+	"github.com/stakpak/devx/pkg/stackbuilder"
+)
+
+var testProject = `
+builders: dev: {
+	environment: "dev"
+	flows: workload: {
+		match: traits: Workload: null
+		exclude: {}
+		pipeline: [{
+			$metadata: traits: Workload: null
+			Image=image: string
+			$resources: service: {
+				$metadata: labels: driver: "compose"
+				image: Image
+			}
+		}]
+	}
+}
+
+noRoot: {
+	$metadata: policy: "no-root"
+	enforced: true
+	pipeline: [{user: !="root"}]
+}
+
+environmentTest: {
+	$metadata: test: "environment adds a service"
+	input: {
+		$metadata: {id: "app", traits: Workload: null}
+		image: "nginx"
+	}
+	environment: "dev"
+	expect: $resources: service: image: "nginx"
+}
+
+flowMismatch: {
+	$metadata: test: "flow output mismatch"
+	input: {
+		$metadata: traits: Workload: null
+		image: "nginx"
+	}
+	environment: "dev"
+	flow: "workload"
+	expect: $resources: service: image: "redis"
+}
+
+policyTest: {
+	$metadata: test: "policy rejects root"
+	input: user: "root"
+	policy: "no-root"
+	expectError: "violates policy no-root"
+}
+
+transformerTest: {
+	$metadata: test: "transformer unexpectedly succeeds"
+	input: port: 80
+	transformer: port: int
+	expectError: true
+}
+`
+
+func TestRunner(t *testing.T) {
+	value := cuecontext.New().CompileString(testProject)
+
+	fixtures, err := LoadFixtures(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) != 4 {
+		t.Fatalf("Expected 4 fixtures but found %d", len(fixtures))
+	}
+
+	builders, err := stackbuilder.NewEnvironments(value.LookupPath(cue.ParsePath("builders")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := policy.LoadPolicies(value, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runner := Runner{Builders: builders, Policies: policies}
+
+	results := map[string]Result{}
+	ordered := []Result{}
+	for _, fixture := range fixtures {
+		result := runner.Run(context.Background(), fixture)
+		results[result.Name] = result
+		ordered = append(ordered, result)
+	}
+
+	for name, passed := range map[string]bool{
+		"environment adds a service":        true,
+		"flow output mismatch":              false,
+		"policy rejects root":               true,
+		"transformer unexpectedly succeeds": false,
+	} {
+		if results[name].Passed != passed {
+			t.Errorf("Expected %s passed to be %v but found %+v", name, passed, results[name])
+		}
+	}
+
+	mismatch := results["flow output mismatch"]
+	if !strings.Contains(mismatch.Diff, `-$resources.service.image: "redis"`) ||
+		!strings.Contains(mismatch.Diff, `+$resources.service.image: "nginx"`) {
+		t.Errorf("Expected image diff but found:\n%s", mismatch.Diff)
+	}
+
+	output := bytes.Buffer{}
+	if err := Print(&output, ordered, "junit"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output.String(), `tests="4" failures="2"`) {
+		t.Errorf("Unexpected junit output:\n%s", output.String())
+	}
+}
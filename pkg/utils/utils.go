@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
 	"net/http"
 	"net/url"
@@ -21,6 +22,7 @@ import (
 	"cuelang.org/go/cue/build"
 	"cuelang.org/go/cue/cuecontext"
 	cueload "cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
 	"github.com/go-git/go-billy/v5"
 	log "github.com/sirupsen/logrus"
 	"github.com/stakpak/devx/pkg/auth"
@@ -30,11 +32,29 @@ import (
 type ContextKey string
 
 const (
-	ConfigDirKey ContextKey = "configDir"
-	DryRunKey    ContextKey = "dryRun"
+	ConfigDirKey    ContextKey = "configDir"
+	DryRunKey       ContextKey = "dryRun"
+	AllowMissingKey ContextKey = "allowMissing"
+	SourceFilesKey  ContextKey = "sourceFiles"
 )
 
+// EnvironmentsDir holds per-environment overlays, files in
+// <EnvironmentsDir>/<environment> are only loaded for that environment
+// and unified with the rest of the project
+const EnvironmentsDir = "env"
+
 func LoadInstances(configDir string, overlays *map[string]string) []*build.Instance {
+	return loadInstances(configDir, overlays, []string{}, false)
+}
+
+// LoadTestProject loads the project including *_test.cue files
+func LoadTestProject(configDir string, overlays *map[string]string) (cue.Value, []*build.Instance) {
+	instances := loadInstances(configDir, overlays, []string{}, true)
+	ctx := cuecontext.New()
+	return ctx.BuildInstance(instances[0]), instances[0].Dependencies()
+}
+
+func loadInstances(configDir string, overlays *map[string]string, args []string, tests bool) []*build.Instance {
 	sourceOverlays := map[string]cueload.Source{}
 
 	if overlays != nil {
@@ -48,17 +68,89 @@ func LoadInstances(configDir string, overlays *map[string]string) []*build.Insta
 	buildConfig := &cueload.Config{
 		Dir:     configDir,
 		Overlay: sourceOverlays,
+		Tests:   tests,
 	}
-	return cueload.Instances([]string{}, buildConfig)
+	return cueload.Instances(args, buildConfig)
 }
 
-func LoadProject(configDir string, overlays *map[string]string) (cue.Value, string, []string) {
-	instances := LoadInstances(configDir, overlays)
+// LoadProject builds the project with the overlay of environment if it has
+// one, the loaded instance is returned for its imports and dependencies
+func LoadProject(configDir string, overlays *map[string]string, environment string) (cue.Value, string, *build.Instance) {
+	args := []string{}
+	envDir := ""
+	// invalid names could escape <EnvironmentsDir>, callers report them with
+	// ValidateEnvironment
+	if environment != "" && ValidateEnvironment(environment) == nil {
+		envDir = path.Join(EnvironmentsDir, environment)
+		if info, err := os.Stat(filepath.Join(configDir, envDir)); (err == nil && info.IsDir()) || hasOverlayDir(overlays, envDir) {
+			// cue merges files of the same package from parent directories,
+			// so loading the environment directory also loads the project
+			log.Debugf("Loading environment overlay %s", envDir)
+			args = append(args, "./"+envDir)
+		} else {
+			envDir = ""
+		}
+	}
+	instances := loadInstances(configDir, overlays, args, false)
 
 	ctx := cuecontext.New()
 	stackID := strings.Split(instances[0].ID(), ":")[0]
+	if envDir != "" {
+		stackID = strings.TrimSuffix(stackID, "/"+envDir)
+	}
+
+	return ctx.BuildInstance(instances[0]), stackID, instances[0]
+}
+
+// ValidateEnvironment checks that an environment name can only refer to a
+// directory directly in <EnvironmentsDir>
+func ValidateEnvironment(environment string) error {
+	if environment == "." || strings.Contains(environment, "..") || strings.ContainsAny(environment, `/\`) {
+		return fmt.Errorf("invalid environment name %q, environment names cannot contain path separators or ..", environment)
+	}
+	return nil
+}
 
-	return ctx.BuildInstance(instances[0]), stackID, instances[0].Deps
+// EnvironmentOverlays lists the environments with an overlay directory in
+// <EnvironmentsDir> either on disk or in overlays
+func EnvironmentOverlays(configDir string, overlays *map[string]string) ([]string, error) {
+	found := map[string]bool{}
+	entries, err := os.ReadDir(filepath.Join(configDir, EnvironmentsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") && !strings.HasPrefix(entry.Name(), "_") {
+			found[entry.Name()] = true
+		}
+	}
+	if overlays != nil {
+		for file := range *overlays {
+			parts := strings.Split(file, "/")
+			if len(parts) > 2 && parts[0] == EnvironmentsDir {
+				found[parts[1]] = true
+			}
+		}
+	}
+
+	environments := make([]string, 0, len(found))
+	for environment := range found {
+		environments = append(environments, environment)
+	}
+	sort.Strings(environments)
+	return environments, nil
+}
+
+func hasOverlayDir(overlays *map[string]string, dir string) bool {
+	if overlays == nil {
+		return false
+	}
+	for file := range *overlays {
+		if strings.HasPrefix(file, dir+"/") {
+			return true
+		}
+	}
+	return false
 }
 
 func GetLastPathFragment(value cue.Value) string {
@@ -203,6 +295,66 @@ func GetOverlays(configDir string) (map[string]string, error) {
 	return overlays, nil
 }
 
+// GetRevisionOverlays builds overlays that replace the project files on disk
+// with files read from another revision, keyed by their path relative to
+// configDir. CUE files that only exist on disk are replaced with an empty file
+// of the same package since overlays cannot remove files, cue.mod is kept as is
+func GetRevisionOverlays(configDir string, files map[string][]byte) (map[string]string, error) {
+	overlays := map[string]string{}
+
+	for name, content := range files {
+		if strings.HasPrefix(name, "cue.mod/") {
+			continue
+		}
+		switch {
+		case strings.HasSuffix(name, ".cue"):
+			overlays[name] = string(content)
+		case !strings.Contains(name, "/") && (strings.HasSuffix(name, ".devx.yaml") || strings.HasSuffix(name, ".devx.yml")):
+			var n yaml.Node
+			if err := yaml.Unmarshal(content, &n); err != nil {
+				return overlays, err
+			}
+			overlays[name+".cue"] = BuildCUEFile("", &n)
+		}
+	}
+
+	err := filepath.WalkDir(configDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(configDir, p)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		if d.IsDir() {
+			if name != "." && (name == "cue.mod" || strings.HasPrefix(d.Name(), ".") || strings.HasPrefix(d.Name(), "_")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(name, ".cue") {
+			return nil
+		}
+		if _, ok := overlays[name]; ok {
+			return nil
+		}
+
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		overlays[name] = ""
+		if f, err := parser.ParseFile(p, content, parser.PackageClauseOnly); err == nil && f.PackageName() != "" {
+			overlays[name] = fmt.Sprintf("package %s\n", f.PackageName())
+		}
+		return nil
+	})
+
+	return overlays, err
+}
+
 func BuildCUEFile(content string, n *yaml.Node) string {
 	newContent := content
 
@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cuelang.org/go/cue"
+)
+
+func writeProjectFile(t *testing.T, dir string, name string, content string) {
+	filePath := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadProjectEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFile(t, dir, "cue.mod/module.cue", `module: "example.com/app"`)
+	writeProjectFile(t, dir, "stack.cue", `package main
+
+stack: components: app: replicas: *1 | int
+`)
+	writeProjectFile(t, dir, "env/prod/stack.cue", `package main
+
+stack: components: app: replicas: 3
+`)
+
+	value, stackId, _ := LoadProject(dir, nil, "")
+	replicas, err := value.LookupPath(cue.ParsePath("stack.components.app.replicas")).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas != 1 {
+		t.Errorf("Expected 1 replica without environment but found %d", replicas)
+	}
+	if stackId != "example.com/app" {
+		t.Errorf("Expected stack id example.com/app but found %s", stackId)
+	}
+
+	value, stackId, _ = LoadProject(dir, nil, "prod")
+	replicas, err = value.LookupPath(cue.ParsePath("stack.components.app.replicas")).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas != 3 {
+		t.Errorf("Expected 3 replicas in prod but found %d", replicas)
+	}
+	if stackId != "example.com/app" {
+		t.Errorf("Expected stack id example.com/app but found %s", stackId)
+	}
+
+	value, _, _ = LoadProject(dir, nil, "dev")
+	replicas, err = value.LookupPath(cue.ParsePath("stack.components.app.replicas")).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas != 1 {
+		t.Errorf("Expected 1 replica in dev but found %d", replicas)
+	}
+}
+
+func TestLoadProjectRevisionOverlays(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFile(t, dir, "cue.mod/module.cue", `module: "example.com/app"`)
+	writeProjectFile(t, dir, "stack.cue", `package main
+
+stack: components: app: replicas: *1 | int
+`)
+	writeProjectFile(t, dir, "extra.cue", `package main
+
+stack: components: app: extra: "only on disk"
+`)
+
+	overlays, err := GetRevisionOverlays(dir, map[string][]byte{
+		"cue.mod/module.cue": []byte(`module: "example.com/other"`),
+		"stack.cue": []byte(`package main
+
+stack: components: app: replicas: *2 | int
+`),
+		"env/prod/stack.cue": []byte(`package main
+
+stack: components: app: replicas: 3
+`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := overlays["cue.mod/module.cue"]; ok {
+		t.Errorf("Expected cue.mod to be kept as is")
+	}
+	if overlays["extra.cue"] != "package main\n" {
+		t.Errorf("Expected extra.cue to be replaced with an empty package but found %q", overlays["extra.cue"])
+	}
+
+	value, stackId, _ := LoadProject(dir, &overlays, "")
+	replicas, err := value.LookupPath(cue.ParsePath("stack.components.app.replicas")).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas != 2 {
+		t.Errorf("Expected 2 replicas from the revision but found %d", replicas)
+	}
+	if value.LookupPath(cue.ParsePath("stack.components.app.extra")).Exists() {
+		t.Errorf("Expected extra field removed in the revision to be missing")
+	}
+	if stackId != "example.com/app" {
+		t.Errorf("Expected stack id example.com/app but found %s", stackId)
+	}
+
+	value, _, _ = LoadProject(dir, &overlays, "prod")
+	replicas, err = value.LookupPath(cue.ParsePath("stack.components.app.replicas")).Int64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replicas != 3 {
+		t.Errorf("Expected 3 replicas in prod from the revision but found %d", replicas)
+	}
+}
+
+func TestEnvironmentOverlays(t *testing.T) {
+	dir := t.TempDir()
+	writeProjectFile(t, dir, "env/prod/stack.cue", "package main\n")
+	writeProjectFile(t, dir, "env/_shared/stack.cue", "package main\n")
+	overlays := map[string]string{"env/dev/stack.cue": "package main\n", "stack.cue": "package main\n"}
+
+	environments, err := EnvironmentOverlays(dir, &overlays)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(environments, ",") != "dev,prod" {
+		t.Errorf("Expected environments dev,prod but found %v", environments)
+	}
+
+	for _, environment := range []string{"../x", "prod/../../x", "a/b", `a\b`, ".", ".."} {
+		if err := ValidateEnvironment(environment); err == nil {
+			t.Errorf("Expected environment %q to be invalid", environment)
+		}
+	}
+	for _, environment := range []string{"", "prod", "eu-west.1"} {
+		if err := ValidateEnvironment(environment); err != nil {
+			t.Errorf("Expected environment %q to be valid but found %s", environment, err)
+		}
+	}
+}